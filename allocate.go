@@ -0,0 +1,158 @@
+package fabricmanager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoPartitionAvailable is returned when no partition of the requested size can be activated
+var ErrNoPartitionAvailable = errors.New("no partition of the requested size can be activated")
+
+// Allocation describes a partition activated by the allocator
+type Allocation struct {
+	PartitionID uint32   `json:"partitionId" yaml:"partitionId"`
+	GPUUUIDs    []string `json:"gpuUuids" yaml:"gpuUuids"`
+	PCIBusIDs   []string `json:"pciBusIds" yaml:"pciBusIds"`
+}
+
+// VisibleDevices returns the GPU list in the format expected by the
+// CUDA_VISIBLE_DEVICES and NVIDIA_VISIBLE_DEVICES environment variables
+func (a *Allocation) VisibleDevices() string {
+	return strings.Join(a.GPUUUIDs, ",")
+}
+
+// normalizePCIBusID lowercases a PCI bus ID and shortens its domain to four
+// digits, so IDs reported as "00000000:07:00.0" and "0000:07:00.0" compare equal
+func normalizePCIBusID(busID string) string {
+	busID = strings.ToLower(strings.TrimSpace(busID))
+	if i := strings.Index(busID, ":"); i > 4 {
+		busID = busID[i-4:]
+	}
+	return busID
+}
+
+// failedGPUSet indexes the GPUs reported with failed NVLinks by UUID and PCI bus ID
+type failedGPUSet struct {
+	uuids  map[string]NvlinkFailedDeviceInfo
+	busIDs map[string]NvlinkFailedDeviceInfo
+}
+
+func newFailedGPUSet(failed *NvlinkFailedDevices) *failedGPUSet {
+	set := &failedGPUSet{
+		uuids:  make(map[string]NvlinkFailedDeviceInfo),
+		busIDs: make(map[string]NvlinkFailedDeviceInfo),
+	}
+	if failed == nil {
+		return set
+	}
+	for _, gpu := range failed.GPUInfo {
+		if gpu.UUID != "" {
+			set.uuids[gpu.UUID] = gpu
+		}
+		if gpu.PCIBusID != "" {
+			set.busIDs[normalizePCIBusID(gpu.PCIBusID)] = gpu
+		}
+	}
+	return set
+}
+
+// lookup returns the failure record of a partition GPU, matched by UUID or PCI bus ID
+func (s *failedGPUSet) lookup(gpu PartitionGPUInfo) (NvlinkFailedDeviceInfo, bool) {
+	if info, ok := s.uuids[gpu.UUID]; ok && gpu.UUID != "" {
+		return info, true
+	}
+	if gpu.PCIBusID != "" {
+		if info, ok := s.busIDs[normalizePCIBusID(gpu.PCIBusID)]; ok {
+			return info, true
+		}
+	}
+	return NvlinkFailedDeviceInfo{}, false
+}
+
+// hasFailedGPU reports whether any GPU of the partition has failed NVLinks
+func (s *failedGPUSet) hasFailedGPU(partition Partition) bool {
+	for _, gpu := range partition.GPUs {
+		if _, ok := s.lookup(gpu); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectPartition chooses an inactive partition of numGPUs GPUs that can be
+// activated given the active state reported in the partition table.
+// Partitions containing a GPU listed in failed are skipped; failed may be nil.
+//
+// To limit fragmentation, the partition blocking the fewest other activatable
+// partitions is preferred. This favours partitions whose siblings are already
+// busy, keeping larger groups of free GPUs intact. Ties go to the lowest ID.
+func SelectPartition(partitions []Partition, failed *NvlinkFailedDevices, numGPUs int) (Partition, error) {
+	g := NewConflictGraph(partitions)
+	available := g.Available(g.ActiveIDs())
+	failedGPUs := newFailedGPUSet(failed)
+
+	best := -1
+	bestBlocked := 0
+	for i, candidate := range available {
+		if len(candidate.GPUs) != numGPUs || failedGPUs.hasFailedGPU(candidate) {
+			continue
+		}
+
+		blocked := 0
+		for _, other := range available {
+			if g.ConflictsWith(candidate.ID, other.ID) {
+				blocked++
+			}
+		}
+
+		if best < 0 || blocked < bestBlocked {
+			best = i
+			bestBlocked = blocked
+		}
+	}
+
+	if best < 0 {
+		return Partition{}, fmt.Errorf("%w: %d GPUs", ErrNoPartitionAvailable, numGPUs)
+	}
+	return available[best], nil
+}
+
+// Allocate selects a partition of numGPUs GPUs with SelectPartition, skipping
+// GPUs with failed NVLinks, and activates it
+func Allocate(pm PartitionManager, numGPUs int) (*Allocation, error) {
+	if numGPUs <= 0 {
+		return nil, fmt.Errorf("invalid number of GPUs: %d", numGPUs)
+	}
+
+	partitions, err := pm.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := pm.GetNvlinkFailedDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	partition, err := SelectPartition(partitions, failed, numGPUs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pm.ActivatePartition(partition.ID); err != nil {
+		return nil, err
+	}
+
+	allocation := &Allocation{PartitionID: partition.ID}
+	for _, gpu := range partition.GPUs {
+		allocation.GPUUUIDs = append(allocation.GPUUUIDs, gpu.UUID)
+		allocation.PCIBusIDs = append(allocation.PCIBusIDs, gpu.PCIBusID)
+	}
+	return allocation, nil
+}
+
+// AllocatePartition selects and activates a partition of numGPUs GPUs
+func (c *Client) AllocatePartition(numGPUs int) (*Allocation, error) {
+	return Allocate(c, numGPUs)
+}
@@ -0,0 +1,139 @@
+package fabricmanager
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fakeManager is an in-memory PartitionManager enforcing GPU conflicts
+type fakeManager struct {
+	partitions  []Partition
+	failed      *NvlinkFailedDevices
+	unsupported []UnsupportedPartition
+	activateErr map[uint32]error
+	calls       []string
+	activated   []uint32
+}
+
+func newFakeManager(partitions []Partition) *fakeManager {
+	return &fakeManager{partitions: partitions, failed: &NvlinkFailedDevices{}}
+}
+
+func (f *fakeManager) GetSupportedPartitions() ([]Partition, error) {
+	partitions := make([]Partition, len(f.partitions))
+	copy(partitions, f.partitions)
+	return partitions, nil
+}
+
+func (f *fakeManager) ActivatePartition(id uint32) error {
+	f.calls = append(f.calls, fmt.Sprintf("activate %d", id))
+	if err := f.activateErr[id]; err != nil {
+		return err
+	}
+	g := NewConflictGraph(f.partitions)
+	if !g.CanActivate(id, g.ActiveIDs()) {
+		return &FMError{Code: FM_ST_RESOURCE_IN_USE, Message: "Resource in use"}
+	}
+	f.setActive(id, true)
+	return nil
+}
+
+func (f *fakeManager) DeactivatePartition(id uint32) error {
+	f.calls = append(f.calls, fmt.Sprintf("deactivate %d", id))
+	for _, partition := range f.partitions {
+		if partition.ID == id && !partition.IsActive {
+			return &FMError{Code: FM_ST_PARTITION_ID_NOT_IN_USE, Message: "Partition ID not in use"}
+		}
+	}
+	f.setActive(id, false)
+	return nil
+}
+
+func (f *fakeManager) GetNvlinkFailedDevices() (*NvlinkFailedDevices, error) {
+	return f.failed, nil
+}
+
+func (f *fakeManager) GetUnsupportedPartitions() ([]UnsupportedPartition, error) {
+	return f.unsupported, nil
+}
+
+func (f *fakeManager) SetActivatedPartitions(ids []uint32) error {
+	f.calls = append(f.calls, "set-activated")
+	f.activated = append([]uint32(nil), ids...)
+	return nil
+}
+
+func (f *fakeManager) setActive(id uint32, active bool) {
+	for i := range f.partitions {
+		if f.partitions[i].ID == id {
+			f.partitions[i].IsActive = active
+		}
+	}
+}
+
+func TestSelectPartitionPrefersBusySiblings(t *testing.T) {
+	// GPU 0 is in use: partition 8 (GPU 1) blocks nothing else, while any
+	// GPU of the second half would break up the free 4-GPU partition 2
+	partition, err := SelectPartition(testPartitionTable(7), nil, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if partition.ID != 8 {
+		t.Errorf("Expected partition 8 next to the busy GPU, got %d", partition.ID)
+	}
+
+	// Partition 3 active: the 2-GPU partition 4 completes the first half
+	partition, err = SelectPartition(testPartitionTable(3), nil, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if partition.ID != 4 {
+		t.Errorf("Expected partition 4, got %d", partition.ID)
+	}
+}
+
+func TestSelectPartitionSkipsFailedGPUs(t *testing.T) {
+	failed := &NvlinkFailedDevices{
+		NumGPUs: 1,
+		GPUInfo: []NvlinkFailedDeviceInfo{{PCIBusID: "0000:11:00.0", NumPorts: 1, PortNums: []uint32{3}}},
+	}
+
+	// GPU 1 (bus 0x11) has failed links, so partition 1 cannot be used
+	partition, err := SelectPartition(testPartitionTable(), failed, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if partition.ID != 2 {
+		t.Errorf("Expected partition 2 without failed GPUs, got %d", partition.ID)
+	}
+
+	if _, err := SelectPartition(testPartitionTable(), failed, 8); !errors.Is(err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected ErrNoPartitionAvailable, got %v", err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	fm := newFakeManager(testPartitionTable(1))
+
+	allocation, err := Allocate(fm, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if allocation.PartitionID != 2 {
+		t.Errorf("Expected partition 2, got %d", allocation.PartitionID)
+	}
+	if !reflect.DeepEqual(fm.calls, []string{"activate 2"}) {
+		t.Errorf("Expected a single activation, got %v", fm.calls)
+	}
+
+	expected := "GPU-00000004,GPU-00000005,GPU-00000006,GPU-00000007"
+	if allocation.VisibleDevices() != expected {
+		t.Errorf("Expected visible devices %s, got %s", expected, allocation.VisibleDevices())
+	}
+
+	if _, err := Allocate(fm, 4); !errors.Is(err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected ErrNoPartitionAvailable once both halves are active, got %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package fabricmanager
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIVersion is the version prefix of the REST API paths
+const APIVersion = "v1"
+
+// APIRole is the set of operations allowed to an API client
+type APIRole string
+
+const (
+	// APIRoleReadOnly allows reading partitions and NVLink failures
+	APIRoleReadOnly APIRole = "read-only"
+	// APIRoleAdmin also allows activating and deactivating partitions
+	APIRoleAdmin APIRole = "admin"
+)
+
+// allows reports whether the role includes the operations of required
+func (r APIRole) allows(required APIRole) bool {
+	return r == APIRoleAdmin || r == required
+}
+
+var (
+	// ErrUnauthenticated is returned for API requests without valid credentials
+	ErrUnauthenticated = errors.New("missing or invalid credentials")
+	// ErrForbidden is returned for API requests whose role does not allow the operation
+	ErrForbidden = errors.New("operation not allowed for this role")
+)
+
+// OpenAPIDocument is the OpenAPI 3 description of the REST API, in YAML
+//
+//go:embed openapi.yaml
+var OpenAPIDocument []byte
+
+// APIToken is a bearer token accepted by the API
+type APIToken struct {
+	// Name identifies the client in logs
+	Name  string  `json:"name" yaml:"name"`
+	Token string  `json:"token" yaml:"token"`
+	Role  APIRole `json:"role" yaml:"role"`
+}
+
+// APIClient is a TLS client certificate accepted by the API, by subject common name
+type APIClient struct {
+	CommonName string  `json:"commonName" yaml:"commonName"`
+	Role       APIRole `json:"role" yaml:"role"`
+}
+
+// APIAuth authenticates API requests with bearer tokens or verified TLS
+// client certificates
+type APIAuth struct {
+	Tokens  []APIToken  `json:"tokens" yaml:"tokens"`
+	Clients []APIClient `json:"clients" yaml:"clients"`
+}
+
+// LoadAPIAuth reads API credentials from a YAML or JSON file
+func LoadAPIAuth(path string) (*APIAuth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API credentials: %w", err)
+	}
+	auth := &APIAuth{}
+	if err := yaml.Unmarshal(data, auth); err != nil {
+		return nil, fmt.Errorf("invalid API credentials file %s: %w", path, err)
+	}
+	if err := auth.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid API credentials file %s: %w", path, err)
+	}
+	return auth, nil
+}
+
+// Validate checks that every credential has a value and a known role
+func (a *APIAuth) Validate() error {
+	for i, token := range a.Tokens {
+		if token.Token == "" {
+			return fmt.Errorf("token %d (%s) is empty", i+1, token.Name)
+		}
+		if token.Role != APIRoleReadOnly && token.Role != APIRoleAdmin {
+			return fmt.Errorf("token %d (%s) has unknown role %q: must be read-only or admin", i+1, token.Name, token.Role)
+		}
+	}
+	for i, client := range a.Clients {
+		if client.CommonName == "" {
+			return fmt.Errorf("client %d has no common name", i+1)
+		}
+		if client.Role != APIRoleReadOnly && client.Role != APIRoleAdmin {
+			return fmt.Errorf("client %s has unknown role %q: must be read-only or admin", client.CommonName, client.Role)
+		}
+	}
+	if len(a.Tokens) == 0 && len(a.Clients) == 0 {
+		return fmt.Errorf("no tokens or clients")
+	}
+	return nil
+}
+
+// Authenticate returns the name and role of the client of a request. A bearer
+// token takes precedence over the TLS client certificate.
+func (a *APIAuth) Authenticate(r *http.Request) (string, APIRole, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", "", ErrUnauthenticated
+		}
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return "", "", ErrUnauthenticated
+		}
+		// Compare digests in constant time, whatever the token lengths
+		digest := sha256.Sum256([]byte(token))
+		for _, known := range a.Tokens {
+			knownDigest := sha256.Sum256([]byte(known.Token))
+			if subtle.ConstantTimeCompare(digest[:], knownDigest[:]) == 1 {
+				return "token " + known.Name, known.Role, nil
+			}
+		}
+		return "", "", ErrUnauthenticated
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for _, client := range a.Clients {
+			if client.CommonName == commonName {
+				return "client " + commonName, client.Role, nil
+			}
+		}
+	}
+	return "", "", ErrUnauthenticated
+}
+
+// APIErrorDetail is the JSON body of API errors, under an "error" key
+type APIErrorDetail struct {
+	Message string `json:"message"`
+	// Code is the FabricManager return code, if the error comes from FabricManager
+	Code *int `json:"code,omitempty"`
+	// Kind is connection, resource or partition for FabricManager errors of these classes
+	Kind string `json:"kind,omitempty"`
+}
+
+// APIStatusCode returns the HTTP status of an API error
+func APIStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	}
+
+	var fmErr *FMError
+	if !errors.As(err, &fmErr) {
+		return http.StatusInternalServerError
+	}
+	switch fmErr.Code {
+	case FM_ST_BADPARAM, FM_ST_PARTITION_ID_NAME_MISMATCH:
+		return http.StatusBadRequest
+	case FM_ST_NOT_SUPPORTED:
+		return http.StatusNotImplemented
+	case FM_ST_IN_USE, FM_ST_RESOURCE_IN_USE, FM_ST_RESOURCE_NOT_IN_USE, FM_ST_RESOURCE_EXHAUSTED,
+		FM_ST_PARTITION_EXISTS, FM_ST_PARTITION_ID_IN_USE, FM_ST_PARTITION_ID_NOT_IN_USE,
+		FM_ST_PARTITION_NAME_IN_USE, FM_ST_PARTITION_NAME_NOT_IN_USE,
+		FM_ST_RESOURCE_USED_IN_THIS_PARTITION, FM_ST_RESOURCE_USED_IN_ANOTHER_PARTITION:
+		return http.StatusConflict
+	case FM_ST_UNINITIALIZED, FM_ST_NOT_CONFIGURED, FM_ST_CONNECTION_NOT_VALID,
+		FM_ST_NOT_READY, FM_ST_RESOURCE_NOT_READY:
+		return http.StatusServiceUnavailable
+	case FM_ST_TIMEOUT:
+		return http.StatusGatewayTimeout
+	case FM_ST_VERSION_MISMATCH:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// newAPIErrorDetail returns the JSON form of an error
+func newAPIErrorDetail(err error) APIErrorDetail {
+	detail := APIErrorDetail{Message: err.Error()}
+	var fmErr *FMError
+	if errors.As(err, &fmErr) {
+		detail.Code = &fmErr.Code
+		switch {
+		case IsConnectionError(fmErr):
+			detail.Kind = "connection"
+		case IsResourceError(fmErr):
+			detail.Kind = "resource"
+		case IsPartitionError(fmErr):
+			detail.Kind = "partition"
+		}
+	}
+	return detail
+}
+
+// apiRequestError is an invalid API request, reported with a 400 status
+type apiRequestError struct {
+	err error
+}
+
+func (e *apiRequestError) Error() string {
+	return e.err.Error()
+}
+
+// APIActionResult is the response of the partition changes
+type APIActionResult struct {
+	Action       string   `json:"action"`
+	PartitionIDs []uint32 `json:"partitionIds"`
+}
+
+// APIServer serves the partition operations of a FabricManager as a REST API
+// under /v1. Read operations require the read-only or admin role, changes
+// require the admin role.
+type APIServer struct {
+	// Connect connects to FabricManager, once per request, on behalf of actor,
+	// the authenticated client. Partition changes made through the connection
+	// should be audited as made for actor, see Client.SetAuditActor.
+	Connect func(actor string) (HostConnection, error)
+	// Auth authenticates requests
+	Auth *APIAuth
+	// Lock runs partition changes, such as under the host lock; nil to run them directly
+	Lock func(fn func() error) error
+	// OnRequest is called after every request with the client name, empty if
+	// the request was not authenticated, and the response status
+	OnRequest func(r *http.Request, client string, status int)
+}
+
+// Handler returns the HTTP handler of the API
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	prefix := "/" + APIVersion
+	mux.HandleFunc("GET "+prefix+"/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(OpenAPIDocument)
+		s.logRequest(r, "", http.StatusOK)
+	})
+	mux.HandleFunc("GET "+prefix+"/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		var document any
+		if err := yaml.Unmarshal(OpenAPIDocument, &document); err != nil {
+			s.writeError(w, r, "", err)
+			return
+		}
+		s.writeJSON(w, r, "", http.StatusOK, document)
+	})
+
+	mux.HandleFunc("GET "+prefix+"/partitions", s.handle(APIRoleReadOnly, func(pm PartitionManager, r *http.Request) (any, error) {
+		partitions, err := pm.GetSupportedPartitions()
+		if err != nil {
+			return nil, err
+		}
+		if partitions == nil {
+			partitions = []Partition{}
+		}
+		return map[string][]Partition{"partitions": partitions}, nil
+	}))
+	mux.HandleFunc("GET "+prefix+"/partitions/unsupported", s.handle(APIRoleReadOnly, func(pm PartitionManager, r *http.Request) (any, error) {
+		partitions, err := pm.GetUnsupportedPartitions()
+		if err != nil {
+			return nil, err
+		}
+		if partitions == nil {
+			partitions = []UnsupportedPartition{}
+		}
+		return map[string][]UnsupportedPartition{"partitions": partitions}, nil
+	}))
+	mux.HandleFunc("GET "+prefix+"/nvlink-failed", s.handle(APIRoleReadOnly, func(pm PartitionManager, r *http.Request) (any, error) {
+		return pm.GetNvlinkFailedDevices()
+	}))
+
+	mux.HandleFunc("POST "+prefix+"/partitions/{id}/activate", s.handle(APIRoleAdmin, func(pm PartitionManager, r *http.Request) (any, error) {
+		id, err := apiPartitionID(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.change(func() error { return pm.ActivatePartition(id) }); err != nil {
+			return nil, err
+		}
+		return APIActionResult{Action: "activate", PartitionIDs: []uint32{id}}, nil
+	}))
+	mux.HandleFunc("POST "+prefix+"/partitions/{id}/deactivate", s.handle(APIRoleAdmin, func(pm PartitionManager, r *http.Request) (any, error) {
+		id, err := apiPartitionID(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.change(func() error { return pm.DeactivatePartition(id) }); err != nil {
+			return nil, err
+		}
+		return APIActionResult{Action: "deactivate", PartitionIDs: []uint32{id}}, nil
+	}))
+	mux.HandleFunc("POST "+prefix+"/partitions/set-activated", s.handle(APIRoleAdmin, func(pm PartitionManager, r *http.Request) (any, error) {
+		var body struct {
+			PartitionIDs []uint32 `json:"partitionIds"`
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&body); err != nil {
+			return nil, &apiRequestError{fmt.Errorf("invalid request body: %w", err)}
+		}
+		if body.PartitionIDs == nil {
+			return nil, &apiRequestError{fmt.Errorf("invalid request body: partitionIds is required")}
+		}
+		if err := s.change(func() error { return pm.SetActivatedPartitions(body.PartitionIDs) }); err != nil {
+			return nil, err
+		}
+		return APIActionResult{Action: "set-activated", PartitionIDs: body.PartitionIDs}, nil
+	}))
+	return mux
+}
+
+// handle authenticates a request, checks its role, and runs fn with a
+// FabricManager connection
+func (s *APIServer) handle(role APIRole, fn func(pm PartitionManager, r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, clientRole, err := s.Auth.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fmpm"`)
+			s.writeError(w, r, "", err)
+			return
+		}
+		if !clientRole.allows(role) {
+			s.writeError(w, r, client, fmt.Errorf("%w: %s requires the %s role", ErrForbidden, r.URL.Path, role))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+		conn, err := s.Connect(client)
+		if err != nil {
+			s.writeError(w, r, client, fmt.Errorf("failed to connect to FabricManager: %w", err))
+			return
+		}
+		defer conn.Disconnect()
+
+		result, err := fn(conn, r)
+		if err != nil {
+			s.writeError(w, r, client, err)
+			return
+		}
+		s.writeJSON(w, r, client, http.StatusOK, result)
+	}
+}
+
+// change runs a partition change under the lock, if any
+func (s *APIServer) change(fn func() error) error {
+	if s.Lock == nil {
+		return fn()
+	}
+	return s.Lock(fn)
+}
+
+// apiPartitionID parses the partition ID of a request path
+func apiPartitionID(r *http.Request) (uint32, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		return 0, &apiRequestError{fmt.Errorf("invalid partition ID %q", r.PathValue("id"))}
+	}
+	return uint32(id), nil
+}
+
+func (s *APIServer) writeError(w http.ResponseWriter, r *http.Request, client string, err error) {
+	status := APIStatusCode(err)
+	var requestErr *apiRequestError
+	if errors.As(err, &requestErr) {
+		status = http.StatusBadRequest
+	}
+	s.writeJSON(w, r, client, status, map[string]APIErrorDetail{"error": newAPIErrorDetail(err)})
+}
+
+func (s *APIServer) writeJSON(w http.ResponseWriter, r *http.Request, client string, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+	s.logRequest(r, client, status)
+}
+
+func (s *APIServer) logRequest(r *http.Request, client string, status int) {
+	if s.OnRequest != nil {
+		s.OnRequest(r, client, status)
+	}
+}
@@ -0,0 +1,357 @@
+package fabricmanager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var testAPIAuth = &APIAuth{
+	Tokens: []APIToken{
+		{Name: "portal", Token: "read-token", Role: APIRoleReadOnly},
+		{Name: "scheduler", Token: "admin-token", Role: APIRoleAdmin},
+	},
+	Clients: []APIClient{{CommonName: "ops", Role: APIRoleAdmin}},
+}
+
+// newTestAPIServer serves the API over a fake manager, nil for a
+// FabricManager that cannot be reached
+func newTestAPIServer(fm *fakeManager) (*APIServer, *atomic.Int32) {
+	disconnected := &atomic.Int32{}
+	return &APIServer{
+		Auth: testAPIAuth,
+		Connect: func(actor string) (HostConnection, error) {
+			if fm == nil {
+				return nil, &FMError{Code: FM_ST_CONNECTION_NOT_VALID, Message: "Connection not valid"}
+			}
+			return fakeHost{fm, disconnected}, nil
+		},
+	}, disconnected
+}
+
+// apiRequest sends a request to the API with a bearer token, if not empty,
+// and decodes the JSON response into out
+func apiRequest(t *testing.T, handler http.Handler, method, path, token, body string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("Invalid JSON response to %s %s: %v: %s", method, path, err, rec.Body.String())
+		}
+	}
+	return rec
+}
+
+// apiError is the error body of API responses
+type apiError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+func TestAPIServerRead(t *testing.T) {
+	fm := newFakeManager(testPartitionTable(1))
+	fm.unsupported = []UnsupportedPartition{{ID: 20, NumGPUs: 2, GPUPhysicalIDs: []uint32{8, 9}}}
+	server, disconnected := newTestAPIServer(fm)
+	handler := server.Handler()
+
+	var partitions struct {
+		Partitions []Partition `json:"partitions"`
+	}
+	if rec := apiRequest(t, handler, "GET", "/v1/partitions", "read-token", "", &partitions); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if !reflect.DeepEqual(partitions.Partitions, testPartitionTable(1)) {
+		t.Errorf("Expected the partition table, got %+v", partitions.Partitions)
+	}
+
+	var unsupported struct {
+		Partitions []UnsupportedPartition `json:"partitions"`
+	}
+	apiRequest(t, handler, "GET", "/v1/partitions/unsupported", "read-token", "", &unsupported)
+	if !reflect.DeepEqual(unsupported.Partitions, fm.unsupported) {
+		t.Errorf("Expected unsupported partition 20, got %+v", unsupported.Partitions)
+	}
+
+	var failed NvlinkFailedDevices
+	if rec := apiRequest(t, handler, "GET", "/v1/nvlink-failed", "admin-token", "", &failed); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for an admin, got %d", rec.Code)
+	}
+
+	if disconnected.Load() != 3 {
+		t.Errorf("Expected 3 disconnections, got %d", disconnected.Load())
+	}
+}
+
+func TestAPIServerAuth(t *testing.T) {
+	fm := newFakeManager(testPartitionTable())
+	server, _ := newTestAPIServer(fm)
+	handler := server.Handler()
+
+	var body apiError
+	rec := apiRequest(t, handler, "GET", "/v1/partitions", "", "", &body)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with a challenge without credentials, got %d", rec.Code)
+	}
+	if rec := apiRequest(t, handler, "GET", "/v1/partitions", "wrong", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown token, got %d", rec.Code)
+	}
+
+	rec = apiRequest(t, handler, "POST", "/v1/partitions/0/activate", "read-token", "", &body)
+	if rec.Code != http.StatusForbidden || !strings.Contains(body.Error.Message, "admin") {
+		t.Errorf("Expected 403 for a read-only client, got %d: %+v", rec.Code, body)
+	}
+	if len(fm.calls) != 0 {
+		t.Errorf("Expected no FabricManager call, got %v", fm.calls)
+	}
+
+	// The OpenAPI document is public
+	if rec := apiRequest(t, handler, "GET", "/v1/openapi.yaml", "", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for the OpenAPI document, got %d", rec.Code)
+	}
+}
+
+func TestAPIAuthClientCertificate(t *testing.T) {
+	request := func(commonName string) *http.Request {
+		req := httptest.NewRequest("GET", "/v1/partitions", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	client, role, err := testAPIAuth.Authenticate(request("ops"))
+	if err != nil || role != APIRoleAdmin || client != "client ops" {
+		t.Errorf("Expected the ops client as admin, got %q, %q, %v", client, role, err)
+	}
+	if _, _, err := testAPIAuth.Authenticate(request("other")); err != ErrUnauthenticated {
+		t.Errorf("Expected ErrUnauthenticated for an unknown client, got %v", err)
+	}
+
+	// Certificates that were not verified are ignored
+	req := request("ops")
+	req.TLS.VerifiedChains = nil
+	if _, _, err := testAPIAuth.Authenticate(req); err != ErrUnauthenticated {
+		t.Errorf("Expected ErrUnauthenticated for an unverified certificate, got %v", err)
+	}
+}
+
+func TestAPIServerChanges(t *testing.T) {
+	fm := newFakeManager(testPartitionTable())
+	server, _ := newTestAPIServer(fm)
+	locked := 0
+	server.Lock = func(fn func() error) error {
+		locked++
+		return fn()
+	}
+	handler := server.Handler()
+
+	var result APIActionResult
+	rec := apiRequest(t, handler, "POST", "/v1/partitions/1/activate", "admin-token", "", &result)
+	if rec.Code != http.StatusOK || result.Action != "activate" || !reflect.DeepEqual(result.PartitionIDs, []uint32{1}) {
+		t.Errorf("Expected partition 1 activated, got %d: %+v", rec.Code, result)
+	}
+
+	// Partition 0 shares GPUs with the active partition 1
+	var body apiError
+	rec = apiRequest(t, handler, "POST", "/v1/partitions/0/activate", "admin-token", "", &body)
+	if rec.Code != http.StatusConflict || body.Error.Code == nil || *body.Error.Code != FM_ST_RESOURCE_IN_USE || body.Error.Kind != "resource" {
+		t.Errorf("Expected 409 with the resource in use code, got %d: %+v", rec.Code, body)
+	}
+
+	rec = apiRequest(t, handler, "POST", "/v1/partitions/1/deactivate", "admin-token", "", &result)
+	if rec.Code != http.StatusOK || result.Action != "deactivate" {
+		t.Errorf("Expected partition 1 deactivated, got %d: %+v", rec.Code, result)
+	}
+
+	rec = apiRequest(t, handler, "POST", "/v1/partitions/set-activated", "admin-token", `{"partitionIds": [2, 3]}`, &result)
+	if rec.Code != http.StatusOK || !reflect.DeepEqual(fm.activated, []uint32{2, 3}) {
+		t.Errorf("Expected partitions 2 and 3 set activated, got %d: %v", rec.Code, fm.activated)
+	}
+
+	if locked != 4 {
+		t.Errorf("Expected 4 changes under the lock, got %d", locked)
+	}
+}
+
+// auditedHost is a fakeHost auditing its activations as a Client does
+type auditedHost struct {
+	fakeHost
+	actor string
+}
+
+func (h auditedHost) ActivatePartition(id uint32) error {
+	start := time.Now()
+	err := h.fakeHost.ActivatePartition(id)
+	audit(AuditActivate, "fake", h.actor, []uint32{id}, start, err)
+	return err
+}
+
+func TestAPIServerAuditActor(t *testing.T) {
+	auditLog := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	SetAuditHook(func(event AuditEvent) {
+		if err := auditLog.Record(event); err != nil {
+			t.Errorf("Failed to record audit event: %v", err)
+		}
+	})
+	defer SetAuditHook(nil)
+
+	fm := newFakeManager(testPartitionTable())
+	server, disconnected := newTestAPIServer(fm)
+	server.Connect = func(actor string) (HostConnection, error) {
+		return auditedHost{fakeHost{fm, disconnected}, actor}, nil
+	}
+
+	if rec := apiRequest(t, server.Handler(), "POST", "/v1/partitions/1/activate", "admin-token", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	entries, err := auditLog.Entries()
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "token scheduler" {
+		t.Errorf("Expected the activation audited for token scheduler, got %+v", entries)
+	}
+}
+
+func TestAPIServerBadRequests(t *testing.T) {
+	fm := newFakeManager(testPartitionTable())
+	server, _ := newTestAPIServer(fm)
+	handler := server.Handler()
+
+	for _, test := range []struct {
+		path, body string
+	}{
+		{"/v1/partitions/abc/activate", ""},
+		{"/v1/partitions/-1/deactivate", ""},
+		{"/v1/partitions/set-activated", `{"ids": [1]}`},
+		{"/v1/partitions/set-activated", `not json`},
+		{"/v1/partitions/set-activated", `{}`},
+	} {
+		var body apiError
+		rec := apiRequest(t, handler, "POST", test.path, "admin-token", test.body, &body)
+		if rec.Code != http.StatusBadRequest || body.Error.Message == "" {
+			t.Errorf("Expected 400 for POST %s %s, got %d: %+v", test.path, test.body, rec.Code, body)
+		}
+	}
+	if len(fm.calls) != 0 {
+		t.Errorf("Expected no FabricManager call, got %v", fm.calls)
+	}
+
+	if rec := apiRequest(t, handler, "DELETE", "/v1/partitions", "admin-token", "", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for DELETE, got %d", rec.Code)
+	}
+}
+
+func TestAPIServerUnreachable(t *testing.T) {
+	server, _ := newTestAPIServer(nil)
+
+	var body apiError
+	rec := apiRequest(t, server.Handler(), "GET", "/v1/partitions", "read-token", "", &body)
+	if rec.Code != http.StatusServiceUnavailable || body.Error.Kind != "connection" {
+		t.Errorf("Expected 503 with a connection error, got %d: %+v", rec.Code, body)
+	}
+}
+
+func TestAPIStatusCode(t *testing.T) {
+	tests := map[int]int{
+		FM_ST_BADPARAM:                           http.StatusBadRequest,
+		FM_ST_NOT_SUPPORTED:                      http.StatusNotImplemented,
+		FM_ST_PARTITION_ID_IN_USE:                http.StatusConflict,
+		FM_ST_PARTITION_ID_NOT_IN_USE:            http.StatusConflict,
+		FM_ST_RESOURCE_USED_IN_ANOTHER_PARTITION: http.StatusConflict,
+		FM_ST_CONNECTION_NOT_VALID:               http.StatusServiceUnavailable,
+		FM_ST_NOT_READY:                          http.StatusServiceUnavailable,
+		FM_ST_TIMEOUT:                            http.StatusGatewayTimeout,
+		FM_ST_VERSION_MISMATCH:                   http.StatusBadGateway,
+		FM_ST_NVLINK_ERROR:                       http.StatusInternalServerError,
+	}
+	for code, status := range tests {
+		if got := APIStatusCode(&FMError{Code: code}); got != status {
+			t.Errorf("Expected %d for FabricManager code %d, got %d", status, code, got)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	var document struct {
+		OpenAPI string                    `yaml:"openapi"`
+		Paths   map[string]map[string]any `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(OpenAPIDocument, &document); err != nil {
+		t.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+
+	routes := map[string]string{
+		"/partitions":                 "get",
+		"/partitions/unsupported":     "get",
+		"/nvlink-failed":              "get",
+		"/partitions/{id}/activate":   "post",
+		"/partitions/{id}/deactivate": "post",
+		"/partitions/set-activated":   "post",
+		"/openapi.yaml":               "get",
+		"/openapi.json":               "get",
+	}
+	for path, method := range routes {
+		if _, ok := document.Paths[path][method]; !ok {
+			t.Errorf("Expected %s %s in the OpenAPI document", method, path)
+		}
+	}
+	if len(document.Paths) != len(routes) {
+		t.Errorf("Expected %d paths, got %d", len(routes), len(document.Paths))
+	}
+
+	server, _ := newTestAPIServer(nil)
+	var served map[string]any
+	if rec := apiRequest(t, server.Handler(), "GET", "/v1/openapi.json", "", "", &served); rec.Code != http.StatusOK || served["openapi"] != document.OpenAPI {
+		t.Errorf("Expected the OpenAPI document as JSON, got %d: %v", rec.Code, served["openapi"])
+	}
+}
+
+func TestLoadAPIAuth(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.yaml")
+	os.WriteFile(path, []byte(`tokens:
+  - name: portal
+    token: secret
+    role: read-only
+clients:
+  - commonName: ops
+    role: admin
+`), 0o600)
+
+	auth, err := LoadAPIAuth(path)
+	if err != nil {
+		t.Fatalf("Failed to load credentials: %v", err)
+	}
+	if len(auth.Tokens) != 1 || auth.Tokens[0].Role != APIRoleReadOnly || len(auth.Clients) != 1 {
+		t.Errorf("Expected one token and one client, got %+v", auth)
+	}
+
+	for _, content := range []string{
+		"tokens:\n  - name: portal\n    token: secret\n    role: root\n",
+		"tokens:\n  - name: portal\n    role: admin\n",
+		"clients:\n  - role: admin\n",
+		"{}\n",
+	} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := LoadAPIAuth(path); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package fabricmanager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audited operations
+const (
+	AuditActivate     = "activate"
+	AuditDeactivate   = "deactivate"
+	AuditSetActivated = "set-activated"
+)
+
+// AuditEvent describes a partition change made through a Client
+type AuditEvent struct {
+	Operation string
+	Address   string
+	// Actor is who the change was made for, such as an API client, if not
+	// the calling user
+	Actor        string
+	PartitionIDs []uint32
+	// ReturnCode is the FabricManager return code, FM_ST_SUCCESS on success
+	ReturnCode int
+	Err        error
+	Start      time.Time
+	Duration   time.Duration
+}
+
+// AuditHook is called after every partition change made through a Client
+type AuditHook func(event AuditEvent)
+
+var (
+	auditMu   sync.RWMutex
+	auditHook AuditHook
+)
+
+// SetAuditHook sets the function called after every ActivatePartition,
+// DeactivatePartition and SetActivatedPartitions call. A nil hook disables auditing.
+func SetAuditHook(hook AuditHook) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditHook = hook
+}
+
+// audit reports a partition change to the audit hook, if any
+func audit(operation, address, actor string, ids []uint32, start time.Time, err error) {
+	auditMu.RLock()
+	hook := auditHook
+	auditMu.RUnlock()
+	if hook == nil {
+		return
+	}
+
+	event := AuditEvent{
+		Operation:    operation,
+		Address:      address,
+		Actor:        actor,
+		PartitionIDs: append([]uint32{}, ids...),
+		ReturnCode:   FM_ST_SUCCESS,
+		Err:          err,
+		Start:        start,
+		Duration:     time.Since(start),
+	}
+	if err != nil {
+		event.ReturnCode = FM_ST_GENERIC_ERROR
+		var fmErr *FMError
+		if errors.As(err, &fmErr) {
+			event.ReturnCode = fmErr.Code
+		}
+	}
+	hook(event)
+}
+
+// AuditEntry is one record of the audit log. Each entry includes the hash of
+// the previous one, so that editing or removing entries breaks the chain.
+type AuditEntry struct {
+	Seq          uint64    `json:"seq" yaml:"seq"`
+	Time         time.Time `json:"time" yaml:"time"`
+	Operation    string    `json:"operation" yaml:"operation"`
+	Address      string    `json:"address,omitempty" yaml:"address,omitempty"`
+	Actor        string    `json:"actor,omitempty" yaml:"actor,omitempty"`
+	PartitionIDs []uint32  `json:"partitionIds" yaml:"partitionIds"`
+	ReturnCode   int       `json:"returnCode" yaml:"returnCode"`
+	Error        string    `json:"error,omitempty" yaml:"error,omitempty"`
+	DurationMs   float64   `json:"durationMs" yaml:"durationMs"`
+	UID          int       `json:"uid" yaml:"uid"`
+	SudoUser     string    `json:"sudoUser,omitempty" yaml:"sudoUser,omitempty"`
+	PID          int       `json:"pid" yaml:"pid"`
+	Cmdline      []string  `json:"cmdline" yaml:"cmdline"`
+	PrevHash     string    `json:"prevHash" yaml:"prevHash"`
+	Hash         string    `json:"hash" yaml:"hash"`
+}
+
+// computeHash returns the hash of the entry with its Hash field cleared
+func (e AuditEntry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAuditEntry records an event along with the identity of the calling process
+func NewAuditEntry(event AuditEvent) AuditEntry {
+	entry := AuditEntry{
+		Time:         event.Start.UTC(),
+		Operation:    event.Operation,
+		Address:      event.Address,
+		Actor:        event.Actor,
+		PartitionIDs: event.PartitionIDs,
+		ReturnCode:   event.ReturnCode,
+		DurationMs:   float64(event.Duration.Microseconds()) / 1000,
+		UID:          os.Getuid(),
+		SudoUser:     os.Getenv("SUDO_USER"),
+		PID:          os.Getpid(),
+		Cmdline:      os.Args,
+	}
+	if entry.PartitionIDs == nil {
+		entry.PartitionIDs = []uint32{}
+	}
+	if event.Err != nil {
+		entry.Error = event.Err.Error()
+	}
+	return entry
+}
+
+// AuditVerifyError locates the first broken link of an audit log
+type AuditVerifyError struct {
+	Line   int
+	Reason string
+}
+
+func (e *AuditVerifyError) Error() string {
+	return fmt.Sprintf("audit log is corrupted at line %d: %s", e.Line, e.Reason)
+}
+
+// AuditLog is a hash-chained JSONL file of partition changes. Appends take an
+// exclusive advisory lock on a companion ".lock" file, so several processes
+// can write to the same log.
+type AuditLog struct {
+	path string
+}
+
+// NewAuditLog creates an audit log backed by the given file
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Path returns the file backing the log
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// lastLine returns the last complete line of the file, or nil if it is empty
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	for chunk := int64(64 << 10); ; chunk *= 2 {
+		offset := max(info.Size()-chunk, 0)
+		data := make([]byte, info.Size()-offset)
+		if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		data = bytes.TrimRight(data, "\n")
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			return data[i+1:], nil
+		}
+		if offset == 0 {
+			if len(data) == 0 {
+				return nil, nil
+			}
+			return data, nil
+		}
+	}
+}
+
+// Record chains an event to the log
+func (l *AuditLog) Record(event AuditEvent) error {
+	return l.Append(NewAuditEntry(event))
+}
+
+// Append sets the sequence number and hashes of entry and appends it to the log
+func (l *AuditLog) Append(entry AuditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	return withFileLock(l.path+".lock", true, func() error {
+		f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o640)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		last, err := lastLine(f)
+		if err != nil {
+			return err
+		}
+		entry.Seq = 1
+		entry.PrevHash = ""
+		if last != nil {
+			var prev AuditEntry
+			if err := json.Unmarshal(last, &prev); err != nil {
+				return fmt.Errorf("invalid last audit entry: %v", err)
+			}
+			entry.Seq = prev.Seq + 1
+			entry.PrevHash = prev.Hash
+		}
+		entry.Hash = entry.computeHash()
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		return f.Sync()
+	})
+}
+
+// Entries reads all entries of the log without verifying the chain
+func (l *AuditLog) Entries() ([]AuditEntry, error) {
+	entries, _, err := l.read()
+	return entries, err
+}
+
+// Verify reads the log and checks the hash chain, returning an
+// *AuditVerifyError for the first entry that was modified, inserted or removed
+func (l *AuditLog) Verify() ([]AuditEntry, error) {
+	entries, lines, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+
+	prevHash := ""
+	for i, entry := range entries {
+		if entry.Seq != uint64(i+1) {
+			return entries, &AuditVerifyError{Line: lines[i], Reason: fmt.Sprintf("expected sequence number %d, got %d", i+1, entry.Seq)}
+		}
+		if entry.PrevHash != prevHash {
+			return entries, &AuditVerifyError{Line: lines[i], Reason: "previous hash does not match the previous entry"}
+		}
+		if entry.computeHash() != entry.Hash {
+			return entries, &AuditVerifyError{Line: lines[i], Reason: "entry hash does not match its content"}
+		}
+		prevHash = entry.Hash
+	}
+	return entries, nil
+}
+
+// read parses the log, returning the entries and their line numbers
+func (l *AuditLog) read() ([]AuditEntry, []int, error) {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []AuditEntry{}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := []AuditEntry{}
+	var lines []int
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, nil, &AuditVerifyError{Line: i + 1, Reason: fmt.Sprintf("invalid JSON: %v", err)}
+		}
+		entries = append(entries, entry)
+		lines = append(lines, i+1)
+	}
+	return entries, lines, nil
+}
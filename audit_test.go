@@ -0,0 +1,129 @@
+package fabricmanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditHook(t *testing.T) {
+	var events []AuditEvent
+	SetAuditHook(func(event AuditEvent) { events = append(events, event) })
+	defer SetAuditHook(nil)
+
+	start := time.Now()
+	audit(AuditActivate, "127.0.0.1:6666", "token scheduler", []uint32{3}, start, nil)
+	audit(AuditDeactivate, "127.0.0.1:6666", "", []uint32{4}, start, &FMError{Code: FM_ST_PARTITION_ID_NOT_IN_USE, Message: "not in use"})
+	audit(AuditSetActivated, "127.0.0.1:6666", "", []uint32{1, 2}, start, errors.New("boom"))
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 audit events, got %d", len(events))
+	}
+	if events[0].Operation != AuditActivate || events[0].ReturnCode != FM_ST_SUCCESS {
+		t.Errorf("Expected a successful activation, got %+v", events[0])
+	}
+	if entry := NewAuditEntry(events[0]); entry.Actor != "token scheduler" {
+		t.Errorf("Expected the actor to be recorded, got %q", entry.Actor)
+	}
+	if events[1].ReturnCode != FM_ST_PARTITION_ID_NOT_IN_USE {
+		t.Errorf("Expected the FM return code to be recorded, got %d", events[1].ReturnCode)
+	}
+	if events[2].ReturnCode != FM_ST_GENERIC_ERROR || !reflect.DeepEqual(events[2].PartitionIDs, []uint32{1, 2}) {
+		t.Errorf("Expected a generic error for set-activated [1 2], got %+v", events[2])
+	}
+
+	SetAuditHook(nil)
+	audit(AuditActivate, "", "", []uint32{3}, start, nil)
+	if len(events) != 3 {
+		t.Errorf("Expected no event once the hook is cleared, got %d", len(events))
+	}
+}
+
+func TestAuditLogChain(t *testing.T) {
+	log := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+
+	for id := uint32(1); id <= 3; id++ {
+		if err := log.Record(AuditEvent{Operation: AuditActivate, PartitionIDs: []uint32{id}, Start: time.Now()}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	entries, err := log.Verify()
+	if err != nil {
+		t.Fatalf("Expected an intact chain, got %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Seq != uint64(i+1) || entry.PID != os.Getpid() || entry.UID != os.Getuid() {
+			t.Errorf("Unexpected entry %d: %+v", i, entry)
+		}
+	}
+	if entries[0].PrevHash != "" || entries[1].PrevHash != entries[0].Hash {
+		t.Errorf("Expected entries to be chained by hash")
+	}
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   int
+	}{
+		{
+			name: "modified entry",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"partitionIds":[2]`, `"partitionIds":[5]`, 1)
+				return lines
+			},
+			line: 2,
+		},
+		{
+			name: "removed entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			line: 2,
+		},
+		{
+			name: "swapped entries",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			line: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			log := NewAuditLog(path)
+			for id := uint32(1); id <= 3; id++ {
+				if err := log.Record(AuditEvent{Operation: AuditDeactivate, PartitionIDs: []uint32{id}, Start: time.Now()}); err != nil {
+					t.Fatalf("Failed to record event: %v", err)
+				}
+			}
+
+			data, _ := os.ReadFile(path)
+			lines := tt.tamper(strings.Split(strings.TrimSpace(string(data)), "\n"))
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o640); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := log.Verify()
+			var verifyErr *AuditVerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("Expected AuditVerifyError, got %v", err)
+			}
+			if verifyErr.Line != tt.line {
+				t.Errorf("Expected tampering detected at line %d, got %d (%s)", tt.line, verifyErr.Line, verifyErr.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package fabricmanager
+
+import (
+	"sort"
+)
+
+// degradation returns the share of max lost in available, as a percentage
+func degradation(available, max uint64) float64 {
+	if max == 0 || available >= max {
+		return 0
+	}
+	return float64(max-available) * 100 / float64(max)
+}
+
+// AvailableBandwidthMBps returns the aggregate bandwidth of the GPU's available NVLinks
+func (g PartitionGPUInfo) AvailableBandwidthMBps() uint64 {
+	return uint64(g.NumNvLinksAvailable) * uint64(g.NvlinkLineRateMBps)
+}
+
+// MaxBandwidthMBps returns the aggregate bandwidth of the GPU with all its NVLinks up
+func (g PartitionGPUInfo) MaxBandwidthMBps() uint64 {
+	return uint64(g.MaxNumNvLinks) * uint64(g.NvlinkLineRateMBps)
+}
+
+// DegradationPercent returns the percentage of the GPU's NVLink bandwidth that is unavailable
+func (g PartitionGPUInfo) DegradationPercent() float64 {
+	return degradation(g.AvailableBandwidthMBps(), g.MaxBandwidthMBps())
+}
+
+// AvailableBandwidthMBps returns the aggregate available NVLink bandwidth of all GPUs in the partition
+func (p Partition) AvailableBandwidthMBps() uint64 {
+	var total uint64
+	for _, gpu := range p.GPUs {
+		total += gpu.AvailableBandwidthMBps()
+	}
+	return total
+}
+
+// MaxBandwidthMBps returns the aggregate NVLink bandwidth of all GPUs in the partition with all links up
+func (p Partition) MaxBandwidthMBps() uint64 {
+	var total uint64
+	for _, gpu := range p.GPUs {
+		total += gpu.MaxBandwidthMBps()
+	}
+	return total
+}
+
+// DegradationPercent returns the percentage of the partition's NVLink bandwidth that is unavailable
+func (p Partition) DegradationPercent() float64 {
+	return degradation(p.AvailableBandwidthMBps(), p.MaxBandwidthMBps())
+}
+
+// BottleneckGPU returns the GPU of the partition with the lowest available
+// NVLink bandwidth. Ties go to the lowest physical ID. It returns false for
+// partitions without GPUs.
+func (p Partition) BottleneckGPU() (PartitionGPUInfo, bool) {
+	if len(p.GPUs) == 0 {
+		return PartitionGPUInfo{}, false
+	}
+	bottleneck := p.GPUs[0]
+	for _, gpu := range p.GPUs[1:] {
+		if gpu.AvailableBandwidthMBps() < bottleneck.AvailableBandwidthMBps() ||
+			(gpu.AvailableBandwidthMBps() == bottleneck.AvailableBandwidthMBps() && gpu.PhysicalID < bottleneck.PhysicalID) {
+			bottleneck = gpu
+		}
+	}
+	return bottleneck, true
+}
+
+// EffectiveBandwidthMBps returns the aggregate bandwidth the partition can
+// sustain when every GPU communicates at the pace of the bottleneck GPU, as in
+// collective operations
+func (p Partition) EffectiveBandwidthMBps() uint64 {
+	bottleneck, ok := p.BottleneckGPU()
+	if !ok {
+		return 0
+	}
+	return bottleneck.AvailableBandwidthMBps() * uint64(len(p.GPUs))
+}
+
+// RankByBandwidth returns the partitions sorted by decreasing effective
+// bandwidth. Ties go to the lowest ID. The input slice is left untouched.
+func RankByBandwidth(partitions []Partition) []Partition {
+	ranked := make([]Partition, len(partitions))
+	copy(ranked, partitions)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		bi, bj := ranked[i].EffectiveBandwidthMBps(), ranked[j].EffectiveBandwidthMBps()
+		if bi != bj {
+			return bi > bj
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	return ranked
+}
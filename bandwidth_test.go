@@ -0,0 +1,65 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGPUBandwidth(t *testing.T) {
+	gpu := PartitionGPUInfo{NumNvLinksAvailable: 9, MaxNumNvLinks: 18, NvlinkLineRateMBps: 1000}
+
+	if gpu.AvailableBandwidthMBps() != 9000 {
+		t.Errorf("Expected 9000 MB/s available, got %d", gpu.AvailableBandwidthMBps())
+	}
+	if gpu.MaxBandwidthMBps() != 18000 {
+		t.Errorf("Expected 18000 MB/s maximum, got %d", gpu.MaxBandwidthMBps())
+	}
+	if gpu.DegradationPercent() != 50 {
+		t.Errorf("Expected 50%% degradation, got %f", gpu.DegradationPercent())
+	}
+
+	if (PartitionGPUInfo{}).DegradationPercent() != 0 {
+		t.Error("Expected no degradation for a GPU without NVLinks")
+	}
+}
+
+func TestPartitionBandwidth(t *testing.T) {
+	partition := testPartition(1, false, 0, 1, 2, 3)
+	partition.GPUs[2].NumNvLinksAvailable = 12
+
+	rate := uint64(partition.GPUs[0].NvlinkLineRateMBps)
+	if partition.MaxBandwidthMBps() != 4*18*rate {
+		t.Errorf("Expected %d MB/s maximum, got %d", 4*18*rate, partition.MaxBandwidthMBps())
+	}
+	if partition.AvailableBandwidthMBps() != (3*18+12)*rate {
+		t.Errorf("Expected %d MB/s available, got %d", (3*18+12)*rate, partition.AvailableBandwidthMBps())
+	}
+
+	bottleneck, ok := partition.BottleneckGPU()
+	if !ok || bottleneck.PhysicalID != 2 {
+		t.Errorf("Expected GPU 2 to be the bottleneck, got %d", bottleneck.PhysicalID)
+	}
+	if partition.EffectiveBandwidthMBps() != 4*12*rate {
+		t.Errorf("Expected %d MB/s effective, got %d", 4*12*rate, partition.EffectiveBandwidthMBps())
+	}
+	if degradation := partition.DegradationPercent(); degradation != 6.0*100/72 {
+		t.Errorf("Expected %f%% degradation, got %f", 6.0*100/72, degradation)
+	}
+}
+
+func TestRankByBandwidth(t *testing.T) {
+	partitions := []Partition{
+		testPartition(1, false, 0, 1),
+		testPartition(2, false, 2, 3),
+		testPartition(3, false, 4, 5, 6, 7),
+	}
+	partitions[0].GPUs[1].NumNvLinksAvailable = 10
+
+	ranked := RankByBandwidth(partitions)
+	if expected := []uint32{3, 2, 1}; !reflect.DeepEqual(partitionIDList(ranked), expected) {
+		t.Errorf("Expected ranking %v, got %v", expected, partitionIDList(ranked))
+	}
+	if partitions[0].ID != 1 {
+		t.Error("Expected the input slice to be left untouched")
+	}
+}
@@ -99,7 +99,7 @@ detect_fabricmanager_version() {
 # Try to build with system library first
 echo -e "${BLUE}Attempting build with system library...${NC}"
 
-if CGO_ENABLED=1 go build -o fmpm cmd/fmpm/main.go; then
+if CGO_ENABLED=1 go build -o fmpm ./cmd/fmpm; then
     echo -e "${GREEN}✓ Build successful with system library!${NC}"
     echo -e "${BLUE}Binary created: ./fmpm${NC}"
 else
@@ -119,7 +119,7 @@ else
         
         echo -e "${BLUE}Building with downloaded runtime library...${NC}"
         
-        if CGO_ENABLED=1 go build -o fmpm cmd/fmpm/main.go; then
+        if CGO_ENABLED=1 go build -o fmpm ./cmd/fmpm; then
             echo -e "${GREEN}✓ Build successful with downloaded library!${NC}"
             echo -e "${BLUE}Binary created: ./fmpm${NC}"
             echo -e "${YELLOW}Note: Runtime library is in $RUNTIME_LIB_DIR/ (will be cleaned up)${NC}"
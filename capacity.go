@@ -0,0 +1,138 @@
+package fabricmanager
+
+import (
+	"sort"
+)
+
+// SizeCapacity is the number of partitions of one size that can be activated together
+type SizeCapacity struct {
+	NumGPUs     int `json:"numGPUs" yaml:"numGPUs"`
+	Supported   int `json:"supported" yaml:"supported"`
+	Activatable int `json:"activatable" yaml:"activatable"`
+}
+
+// CapacityReport summarizes how the free GPUs of a node can still be used
+type CapacityReport struct {
+	TotalGPUs          int            `json:"totalGPUs" yaml:"totalGPUs"`
+	FreeGPUs           int            `json:"freeGPUs" yaml:"freeGPUs"`
+	ActivePartitions   []uint32       `json:"activePartitions" yaml:"activePartitions"`
+	LargestActivatable int            `json:"largestActivatable" yaml:"largestActivatable"`
+	Sizes              []SizeCapacity `json:"sizes" yaml:"sizes"`
+	// FragmentationScore is 0 when all free GPUs can be used by a single
+	// partition and approaches 1 as free GPUs are scattered across small ones
+	FragmentationScore float64 `json:"fragmentationScore" yaml:"fragmentationScore"`
+}
+
+// Activatable returns how many partitions of numGPUs GPUs can be activated together
+func (r *CapacityReport) Activatable(numGPUs int) int {
+	for _, size := range r.Sizes {
+		if size.NumGPUs == numGPUs {
+			return size.Activatable
+		}
+	}
+	return 0
+}
+
+// maxDisjoint returns the largest number of pairwise disjoint GPU sets
+func maxDisjoint(sets []map[uint32]struct{}) int {
+	best := 0
+	used := make(map[uint32]struct{})
+
+	var search func(i, count int)
+	search = func(i, count int) {
+		if count > best {
+			best = count
+		}
+		if i == len(sets) || count+len(sets)-i <= best {
+			return
+		}
+
+		// Take sets[i] if it does not overlap what is already used
+		overlaps := false
+		for id := range sets[i] {
+			if _, ok := used[id]; ok {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			for id := range sets[i] {
+				used[id] = struct{}{}
+			}
+			search(i+1, count+1)
+			for id := range sets[i] {
+				delete(used, id)
+			}
+		}
+
+		// Skip sets[i]
+		search(i+1, count)
+	}
+	search(0, 0)
+
+	return best
+}
+
+// NewCapacityReport computes the capacity of a node from its partition table
+// and the active state reported in it
+func NewCapacityReport(partitions []Partition) *CapacityReport {
+	g := NewConflictGraph(partitions)
+	active := g.ActiveIDs()
+
+	report := &CapacityReport{ActivePartitions: active}
+	if report.ActivePartitions == nil {
+		report.ActivePartitions = []uint32{}
+	}
+
+	// Count every GPU known to the table and the ones used by active partitions
+	all := make(map[uint32]struct{})
+	busy := make(map[uint32]struct{})
+	for _, partition := range g.Partitions() {
+		for _, gpu := range partition.GPUs {
+			all[gpu.PhysicalID] = struct{}{}
+			if partition.IsActive {
+				busy[gpu.PhysicalID] = struct{}{}
+			}
+		}
+	}
+	report.TotalGPUs = len(all)
+	report.FreeGPUs = len(all) - len(busy)
+
+	// Group partitions by size
+	total := make(map[int]int)
+	available := make(map[int][]map[uint32]struct{})
+	for _, partition := range g.Partitions() {
+		size := len(partition.GPUs)
+		total[size]++
+		if g.CanActivate(partition.ID, active) {
+			available[size] = append(available[size], gpuSet(partition))
+			if size > report.LargestActivatable {
+				report.LargestActivatable = size
+			}
+		}
+	}
+
+	for size, count := range total {
+		report.Sizes = append(report.Sizes, SizeCapacity{
+			NumGPUs:     size,
+			Supported:   count,
+			Activatable: maxDisjoint(available[size]),
+		})
+	}
+	sort.Slice(report.Sizes, func(i, j int) bool { return report.Sizes[i].NumGPUs > report.Sizes[j].NumGPUs })
+
+	if report.FreeGPUs > 0 {
+		report.FragmentationScore = 1 - float64(report.LargestActivatable)/float64(report.FreeGPUs)
+	}
+
+	return report
+}
+
+// GetCapacity computes the capacity report of the connected FabricManager
+func (c *Client) GetCapacity() (*CapacityReport, error) {
+	partitions, err := c.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	return NewCapacityReport(partitions), nil
+}
@@ -0,0 +1,66 @@
+package fabricmanager
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCapacityReportIdle(t *testing.T) {
+	report := NewCapacityReport(testPartitionTable())
+
+	if report.TotalGPUs != 8 || report.FreeGPUs != 8 {
+		t.Errorf("Expected 8 free GPUs of 8, got %d of %d", report.FreeGPUs, report.TotalGPUs)
+	}
+	if report.LargestActivatable != 8 {
+		t.Errorf("Expected largest activatable partition of 8 GPUs, got %d", report.LargestActivatable)
+	}
+	if report.FragmentationScore != 0 {
+		t.Errorf("Expected no fragmentation, got %f", report.FragmentationScore)
+	}
+
+	expected := map[int]int{8: 1, 4: 2, 2: 4, 1: 8}
+	for size, count := range expected {
+		if report.Activatable(size) != count {
+			t.Errorf("Expected %d activatable %d-GPU partitions, got %d", count, size, report.Activatable(size))
+		}
+	}
+}
+
+func TestCapacityReportFragmented(t *testing.T) {
+	// GPUs 0 and 4 are busy: no 4-GPU partition is left
+	report := NewCapacityReport(testPartitionTable(7, 11))
+
+	if report.FreeGPUs != 6 {
+		t.Errorf("Expected 6 free GPUs, got %d", report.FreeGPUs)
+	}
+	if report.LargestActivatable != 2 {
+		t.Errorf("Expected largest activatable partition of 2 GPUs, got %d", report.LargestActivatable)
+	}
+
+	expected := map[int]int{8: 0, 4: 0, 2: 2, 1: 6}
+	for size, count := range expected {
+		if report.Activatable(size) != count {
+			t.Errorf("Expected %d activatable %d-GPU partitions, got %d", count, size, report.Activatable(size))
+		}
+	}
+
+	if math.Abs(report.FragmentationScore-2.0/3.0) > 1e-9 {
+		t.Errorf("Expected fragmentation score 0.667, got %f", report.FragmentationScore)
+	}
+}
+
+func TestMaxDisjoint(t *testing.T) {
+	sets := []map[uint32]struct{}{
+		{1: {}, 2: {}},
+		{0: {}, 1: {}},
+		{2: {}, 3: {}},
+	}
+	// Taking the first set would leave no room for the other two
+	if count := maxDisjoint(sets); count != 2 {
+		t.Errorf("Expected 2 disjoint sets, got %d", count)
+	}
+
+	if count := maxDisjoint(nil); count != 0 {
+		t.Errorf("Expected 0 disjoint sets, got %d", count)
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Agent flags
+	agentDesiredStateFile string
+	agentPrune            bool
+	agentInterval         time.Duration
+	agentSocket           string
+
+	// Agent command
+	agentCmd = &cobra.Command{
+		Use:   "agent",
+		Short: "Keep the active partitions converged to a desired state",
+		Long: `Run a long-lived agent that keeps the active partitions converged to a desired
+state file (see "fmpm diff --help" for the format).
+
+The agent reconnects when FabricManager restarts. When FabricManager comes back
+in resiliency mode and waits for the activated partition list, the agent hands
+it the partitions that were active before the restart, then re-applies the
+desired state. Status is served as JSON over a local UNIX socket.
+
+Expired partition leases are reaped on every reconciliation, and partitions
+under a valid lease are kept active even with --prune.
+
+SIGHUP reloads the desired state file; SIGINT and SIGTERM stop the agent.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if agentInterval <= 0 {
+				return fmt.Errorf("--interval must be a positive duration")
+			}
+			printContextHeader()
+
+			desired, err := fabricmanager.LoadDesiredState(agentDesiredStateFile)
+			if err != nil {
+				return fmt.Errorf("failed to load desired state: %w", err)
+			}
+
+			a := &agent{
+				address:     fabricManagerAddress(),
+				desiredFile: agentDesiredStateFile,
+				desired:     desired,
+				prune:       agentPrune,
+				status: agentStatus{
+					StartedAt:        time.Now().UTC(),
+					DesiredStateFile: agentDesiredStateFile,
+				},
+			}
+			return a.run()
+		},
+	}
+
+	// Agent status command
+	agentStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of a running agent",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := &http.Client{
+				Timeout: 5 * time.Second,
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						var d net.Dialer
+						return d.DialContext(ctx, "unix", agentSocket)
+					},
+				},
+			}
+
+			resp, err := client.Get("http://agent/status")
+			if err != nil {
+				return fmt.Errorf("failed to reach agent at %s: %w", agentSocket, err)
+			}
+			defer resp.Body.Close()
+
+			var status agentStatus
+			if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+				return fmt.Errorf("invalid agent status: %w", err)
+			}
+
+			return printResult(status, nil, func() {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				_ = encoder.Encode(status)
+			})
+		},
+	}
+)
+
+// agentStatus is the status reported by the agent over its socket
+type agentStatus struct {
+	StartedAt         time.Time  `json:"startedAt" yaml:"startedAt"`
+	DesiredStateFile  string     `json:"desiredStateFile" yaml:"desiredStateFile"`
+	Connected         bool       `json:"connected" yaml:"connected"`
+	DesiredPartitions []uint32   `json:"desiredPartitions" yaml:"desiredPartitions"`
+	ActivePartitions  []uint32   `json:"activePartitions" yaml:"activePartitions"`
+	LastReconcile     *time.Time `json:"lastReconcile,omitempty" yaml:"lastReconcile,omitempty"`
+	LastChange        *time.Time `json:"lastChange,omitempty" yaml:"lastChange,omitempty"`
+	LastReload        *time.Time `json:"lastReload,omitempty" yaml:"lastReload,omitempty"`
+	LastError         string     `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+	Reconciles        int        `json:"reconciles" yaml:"reconciles"`
+	Changes           int        `json:"changes" yaml:"changes"`
+	Restores          int        `json:"restores" yaml:"restores"`
+}
+
+// agent reconciles the active partitions with a desired state
+type agent struct {
+	address     string
+	desiredFile string
+	prune       bool
+
+	// client is only used by the reconcile loop
+	client     *fabricmanager.Client
+	lastActive []uint32
+
+	mu      sync.Mutex
+	desired *fabricmanager.DesiredState
+	status  agentStatus
+}
+
+func (a *agent) run() error {
+	listener, err := listenUnix(agentSocket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", agentSocket, err)
+	}
+	defer os.Remove(agentSocket)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.serveStatus)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Status server stopped: %v", err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	log.Printf("Agent started: desired state %s, FabricManager %s, status socket %s", a.desiredFile, a.address, agentSocket)
+
+	ticker := time.NewTicker(agentInterval)
+	defer ticker.Stop()
+
+	a.reconcile()
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				a.reload()
+				a.reconcile()
+				continue
+			}
+
+			log.Printf("Received %s, shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Printf("Warning: failed to stop status server: %v", err)
+			}
+			a.disconnect()
+			return nil
+		case <-ticker.C:
+			a.reconcile()
+		}
+	}
+}
+
+// listenUnix listens on a UNIX socket, replacing a stale socket file
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another agent is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func (a *agent) serveStatus(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	status := a.status
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Failed to write status: %v", err)
+	}
+}
+
+// reload reads the desired state file again, keeping the previous state if it is invalid
+func (a *agent) reload() {
+	desired, err := fabricmanager.LoadDesiredState(a.desiredFile)
+	if err != nil {
+		log.Printf("Failed to reload desired state, keeping the previous one: %v", err)
+		a.setError(err)
+		return
+	}
+
+	now := time.Now().UTC()
+	a.mu.Lock()
+	a.desired = desired
+	a.status.LastReload = &now
+	a.mu.Unlock()
+	log.Printf("Reloaded desired state from %s", a.desiredFile)
+}
+
+func (a *agent) setError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.status.LastError = err.Error()
+	} else {
+		a.status.LastError = ""
+	}
+}
+
+func (a *agent) disconnect() {
+	if a.client != nil {
+		a.client.Disconnect()
+		a.client = nil
+	}
+	a.mu.Lock()
+	a.status.Connected = false
+	a.mu.Unlock()
+}
+
+// reconcile converges the active partitions under the host lock, skipping this
+// round if another fmpm process keeps the lock
+func (a *agent) reconcile() {
+	if err := withHostLock(func() error {
+		a.reconcileLocked()
+		return nil
+	}); err != nil {
+		log.Printf("Skipping reconciliation: %v", err)
+		a.setError(err)
+	}
+}
+
+// reconcileLocked connects to FabricManager if needed and converges the active partitions
+func (a *agent) reconcileLocked() {
+	a.mu.Lock()
+	desired := a.desired
+	a.mu.Unlock()
+
+	if a.client == nil {
+		client, err := connectAddress(a.address)
+		if err != nil {
+			a.setError(fmt.Errorf("failed to connect to FabricManager at %s: %w", describeAddress(a.address), err))
+			return
+		}
+		log.Printf("Connected to FabricManager at %s", describeAddress(a.address))
+		a.client = client
+		a.mu.Lock()
+		a.status.Connected = true
+		a.mu.Unlock()
+	}
+
+	reapLeases(fabricmanager.NewLeaseStore(leaseFile), a.client)
+
+	partitions, err := a.client.GetSupportedPartitions()
+	if err != nil && fabricmanager.IsAwaitingActivatedList(err) {
+		// FabricManager restarted in resiliency mode and waits for the partitions that were active
+		err = a.restore(desired)
+		if err == nil {
+			partitions, err = a.client.GetSupportedPartitions()
+		}
+	}
+	if err != nil {
+		if fabricmanager.IsConnectionError(err) {
+			log.Printf("Lost connection to FabricManager: %v", err)
+			a.disconnect()
+		}
+		a.setError(err)
+		return
+	}
+
+	plan, err := fabricmanager.NewPlan(withLeases(desired, partitions), partitions, a.prune)
+	if err == nil && plan.HasChanges() {
+		log.Printf("Applying desired state:\n%s", plan)
+		var report *fabricmanager.TransactionReport
+		report, err = plan.Apply(a.client)
+		for _, step := range report.Steps {
+			log.Printf("  %s", step)
+		}
+		if err == nil {
+			now := time.Now().UTC()
+			a.mu.Lock()
+			a.status.Changes++
+			a.status.LastChange = &now
+			a.mu.Unlock()
+			partitions, err = a.client.GetSupportedPartitions()
+		}
+	}
+
+	now := time.Now().UTC()
+	a.mu.Lock()
+	a.status.Reconciles++
+	a.status.LastReconcile = &now
+	if ids, resolveErr := desired.Resolve(partitions); resolveErr == nil {
+		a.status.DesiredPartitions = ids
+	}
+	if err == nil {
+		a.lastActive = fabricmanager.NewConflictGraph(partitions).ActiveIDs()
+		a.status.ActivePartitions = a.lastActive
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+	}
+	a.setError(err)
+}
+
+// withLeases adds the leased partitions to the desired state so that they are
+// never pruned while their lease is valid
+func withLeases(desired *fabricmanager.DesiredState, partitions []fabricmanager.Partition) *fabricmanager.DesiredState {
+	leases, err := fabricmanager.NewLeaseStore(leaseFile).List()
+	if err != nil {
+		log.Printf("Warning: failed to read leases: %v", err)
+		return desired
+	}
+	declared, err := desired.Resolve(partitions)
+	if err != nil || len(leases) == 0 {
+		return desired
+	}
+
+	merged := &fabricmanager.DesiredState{Partitions: append([]fabricmanager.DesiredPartition{}, desired.Partitions...)}
+	now := time.Now()
+	for _, lease := range leases {
+		if lease.Expired(now) || slices.Contains(declared, lease.PartitionID) {
+			continue
+		}
+		id := lease.PartitionID
+		merged.Partitions = append(merged.Partitions, fabricmanager.DesiredPartition{ID: &id})
+	}
+	return merged
+}
+
+// restore hands the partitions active before a FabricManager restart back to it.
+// Before the first successful reconciliation, the desired partitions given by
+// ID are used instead.
+func (a *agent) restore(desired *fabricmanager.DesiredState) error {
+	ids := a.lastActive
+	if ids == nil {
+		for _, partition := range desired.Partitions {
+			if partition.ID != nil {
+				ids = append(ids, *partition.ID)
+			}
+		}
+	}
+
+	log.Printf("FabricManager is in resiliency mode, setting activated partitions %v", ids)
+	if err := a.client.SetActivatedPartitions(ids); err != nil {
+		return fmt.Errorf("failed to set activated partitions: %w", err)
+	}
+
+	a.mu.Lock()
+	a.status.Restores++
+	a.mu.Unlock()
+	return nil
+}
+
+func init() {
+	agentCmd.Flags().StringVarP(&agentDesiredStateFile, "filename", "f", "", "desired state file (YAML or JSON)")
+	agentCmd.Flags().BoolVar(&agentPrune, "prune", false, "deactivate active partitions not declared in the desired state")
+	agentCmd.Flags().DurationVar(&agentInterval, "interval", 30*time.Second, "reconciliation interval")
+	_ = agentCmd.MarkFlagRequired("filename")
+	agentCmd.PersistentFlags().StringVar(&agentSocket, "socket", "/run/fmpm/agent.sock", "UNIX socket serving the agent status")
+
+	agentCmd.AddCommand(agentStatusCmd)
+	rootCmd.AddCommand(agentCmd)
+}
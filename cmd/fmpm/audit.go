@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Audit flags
+	auditLogFile   string
+	auditPartition int
+	auditSince     time.Duration
+	auditLimit     int
+
+	// Audit command
+	auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Query the audit log of partition changes",
+		Long: `Every activation, deactivation and activated partition list set by fmpm is
+recorded in a hash-chained JSONL audit log with the calling user, PID, command
+line, FabricManager return code and duration. Changes made through "fmpm serve"
+also record the API client they were made for. Each entry includes the hash of
+the previous one, so modified, inserted or removed entries are detected by
+"fmpm audit verify". Truncation of the newest entries can only be detected by
+comparing with a copy of the last hash kept elsewhere.`,
+	}
+
+	// Audit show command
+	auditShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show audit log entries",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := fabricmanager.NewAuditLog(auditLogFile).Entries()
+			if err != nil {
+				return fmt.Errorf("failed to read audit log: %w", err)
+			}
+
+			var selected []fabricmanager.AuditEntry
+			for _, entry := range entries {
+				if auditPartition >= 0 && !containsID(entry.PartitionIDs, uint32(auditPartition)) {
+					continue
+				}
+				if auditSince > 0 && entry.Time.Before(time.Now().Add(-auditSince)) {
+					continue
+				}
+				selected = append(selected, entry)
+			}
+			if auditLimit > 0 && len(selected) > auditLimit {
+				selected = selected[len(selected)-auditLimit:]
+			}
+
+			if selected == nil {
+				selected = []fabricmanager.AuditEntry{}
+			}
+
+			table := newResultTable(3, "SEQ", "TIME", "USER", "OPERATION", "PARTITIONS", "RC", "DURATION MS", "PID", "COMMAND", "HASH")
+			for _, entry := range selected {
+				table.addRow(strconv.FormatUint(entry.Seq, 10), entry.Time.Format(time.RFC3339), auditCaller(entry),
+					entry.Operation, joinIDs(entry.PartitionIDs), strconv.Itoa(entry.ReturnCode),
+					strconv.FormatFloat(entry.DurationMs, 'f', 1, 64), strconv.Itoa(entry.PID),
+					strings.Join(entry.Cmdline, " "), entry.Hash)
+			}
+
+			return printResult(selected, table, func() {
+				if len(selected) == 0 {
+					fmt.Println("No audit entries")
+					return
+				}
+				for _, entry := range selected {
+					result := "ok"
+					if entry.ReturnCode != fabricmanager.FM_ST_SUCCESS {
+						result = fmt.Sprintf("rc=%d %s", entry.ReturnCode, entry.Error)
+					}
+					fmt.Printf("#%d %s %s %s [%s] %s (%.1fms)\n", entry.Seq, entry.Time.Local().Format(time.RFC3339),
+						auditCaller(entry), entry.Operation, joinIDs(entry.PartitionIDs), result, entry.DurationMs)
+					fmt.Printf("    pid %d: %s\n", entry.PID, strings.Join(entry.Cmdline, " "))
+				}
+			})
+		},
+	}
+
+	// Audit verify command
+	auditVerifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Check the audit log hash chain for tampering",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := fabricmanager.NewAuditLog(auditLogFile).Verify()
+			if err != nil {
+				return err
+			}
+
+			result := auditVerifyResult{Path: auditLogFile, Entries: len(entries)}
+			if len(entries) > 0 {
+				result.LastHash = entries[len(entries)-1].Hash
+			}
+
+			return printResult(result, nil, func() {
+				fmt.Printf("Audit log %s is intact: %d entries\n", result.Path, result.Entries)
+				if result.LastHash != "" {
+					fmt.Printf("Last hash: %s\n", result.LastHash)
+				}
+			})
+		},
+	}
+)
+
+// auditVerifyResult is the structured output of "fmpm audit verify"
+type auditVerifyResult struct {
+	Path     string `json:"path" yaml:"path"`
+	Entries  int    `json:"entries" yaml:"entries"`
+	LastHash string `json:"lastHash,omitempty" yaml:"lastHash,omitempty"`
+}
+
+// auditCaller names the user behind an audit entry
+func auditCaller(entry fabricmanager.AuditEntry) string {
+	name := "uid " + strconv.Itoa(entry.UID)
+	if u, err := user.LookupId(strconv.Itoa(entry.UID)); err == nil {
+		name = u.Username
+	}
+	if entry.SudoUser != "" {
+		name += " (sudo " + entry.SudoUser + ")"
+	}
+	if entry.Actor != "" {
+		name += " for " + entry.Actor
+	}
+	return name
+}
+
+func containsID(ids []uint32, id uint32) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAuditEvent appends a partition change made by this process to the audit log
+func recordAuditEvent(event fabricmanager.AuditEvent) {
+	if auditLogFile == "" {
+		return
+	}
+	if err := fabricmanager.NewAuditLog(auditLogFile).Record(event); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log %s: %v\n", auditLogFile, err)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&auditLogFile, "audit-log", "/var/log/fmpm/audit.jsonl", "audit log of partition changes (empty to disable)")
+	fabricmanager.SetAuditHook(recordAuditEvent)
+
+	auditShowCmd.Flags().IntVar(&auditPartition, "partition", -1, "only show entries for this partition ID")
+	auditShowCmd.Flags().DurationVar(&auditSince, "since", 0, "only show entries newer than this duration")
+	auditShowCmd.Flags().IntVar(&auditLimit, "limit", 0, "only show the last N entries")
+
+	auditCmd.AddCommand(auditShowCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// systemConfigFile is the config file shared by all users
+	systemConfigFile = "/etc/fmpm/config.yaml"
+	// configFileEnv overrides the user and system config files
+	configFileEnv = "FM_CONFIG_FILE"
+)
+
+// configKeys are the global flags that can be set in config files, in display order
+var configKeys = []string{
+	"hostname",
+	"port",
+	"unix-domain-socket",
+	"timeout",
+	"output",
+	"lease-file",
+	"lock-file",
+	"lock-timeout",
+	"audit-log",
+	"parallel",
+	"host-timeout",
+	"ssh",
+	"ssh-jump",
+	"ssh-identity",
+	"ssh-known-hosts",
+}
+
+// configEnv maps config keys to the environment variables overriding them
+var configEnv = map[string]string{
+	"hostname": "FM_HOST",
+	"port":     "FM_PORT",
+	"timeout":  "FM_TIMEOUT",
+}
+
+var (
+	// Config flags
+	configSystem bool
+
+	// configSources records where each effective setting came from
+	configSources = map[string]string{}
+
+	// Config command
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Show and change fmpm settings",
+		Long: `Show and change the settings used by fmpm. Each setting is taken from, in order
+of precedence: its command-line flag, the context selected with --context, its
+environment variable (FM_HOST, FM_PORT and FM_TIMEOUT), the current context, the
+user config file (~/.config/fmpm/config.yaml), the system config file
+(/etc/fmpm/config.yaml) and the built-in default.
+
+FM_CONFIG_FILE replaces the user and system config files with another file.`,
+	}
+
+	// Config view command
+	configViewCmd = &cobra.Command{
+		Use:   "view",
+		Short: "Show the effective settings and where each one came from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings := make([]configSetting, 0, len(configKeys))
+			table := newResultTable(0, "KEY", "VALUE", "SOURCE")
+			for _, key := range configKeys {
+				setting := effectiveSetting(key)
+				settings = append(settings, setting)
+				table.addRow(setting.Key, setting.Value, setting.Source)
+			}
+
+			return printResult(settings, table, func() {
+				for _, setting := range settings {
+					fmt.Printf("%s: %s (%s)\n", setting.Key, setting.Value, setting.Source)
+				}
+			})
+		},
+	}
+
+	// Config get command
+	configGetCmd = &cobra.Command{
+		Use:   "get <key>",
+		Short: "Show the effective value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkConfigKey(args[0]); err != nil {
+				return err
+			}
+
+			setting := effectiveSetting(args[0])
+			return printResult(setting, nil, func() {
+				fmt.Println(setting.Value)
+			})
+		},
+	}
+
+	// Config set command
+	configSetCmd = &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Write a setting to the user config file",
+		Long: `Write a setting to the user config file, or to the system config file with
+--system. An empty value removes the setting from the file.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+			if err := checkConfigKey(key); err != nil {
+				return err
+			}
+			if value != "" {
+				if err := validateConfigValue(key, value); err != nil {
+					return fmt.Errorf("invalid value for %s: %w", key, err)
+				}
+			}
+
+			path := configWritePath(configSystem)
+			config, err := loadConfigFile(path)
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				delete(config.Settings, key)
+			} else {
+				config.Settings[key] = value
+			}
+			if err := config.save(path); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+
+			return printResult(configSetting{Key: key, Value: value, Source: "file " + path}, nil, func() {
+				if value == "" {
+					fmt.Printf("Removed %s from %s\n", key, path)
+				} else {
+					fmt.Printf("Set %s to %s in %s\n", key, value, path)
+				}
+			})
+		},
+	}
+)
+
+// validateConfigValue parses value with a scratch flag of the type of the
+// global flag key, leaving the flags of this process unchanged
+func validateConfigValue(key, value string) error {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	switch kind := rootCmd.PersistentFlags().Lookup(key).Value.Type(); kind {
+	case "string":
+		flags.String(key, "", "")
+	case "int":
+		flags.Int(key, 0, "")
+	case "duration":
+		flags.Duration(key, 0, "")
+	default:
+		return fmt.Errorf("unsupported setting type %s", kind)
+	}
+	return flags.Set(key, value)
+}
+
+// configSetting is an effective setting with its source
+type configSetting struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// configFile is the content of an fmpm config file
+type configFile struct {
+	CurrentContext string                       `yaml:"current-context,omitempty"`
+	Contexts       map[string]connectionContext `yaml:"contexts,omitempty"`
+	Settings       map[string]string            `yaml:",inline"`
+}
+
+// userConfigFile returns the path of the config file of the current user
+func userConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "fmpm", "config.yaml")
+}
+
+// loadConfigFile reads a config file, a missing file is empty
+func loadConfigFile(path string) (*configFile, error) {
+	config := &configFile{Settings: map[string]string{}, Contexts: map[string]connectionContext{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if config.Settings == nil {
+		config.Settings = map[string]string{}
+	}
+	if config.Contexts == nil {
+		config.Contexts = map[string]connectionContext{}
+	}
+	for key := range config.Settings {
+		if err := checkConfigKey(key); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	return config, nil
+}
+
+func (c *configFile) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func checkConfigKey(key string) error {
+	for _, known := range configKeys {
+		if key == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown setting %q: must be one of %s", key, strings.Join(configKeys, ", "))
+}
+
+// configWritePath returns the config file changed by fmpm config set and
+// fmpm context, the system one if system is true
+func configWritePath(system bool) string {
+	if override := os.Getenv(configFileEnv); override != "" {
+		return override
+	}
+	if system {
+		return systemConfigFile
+	}
+	return userConfigFile()
+}
+
+// configFiles returns the config files to read, highest precedence first
+func configFiles() []string {
+	if override := os.Getenv(configFileEnv); override != "" {
+		return []string{override}
+	}
+	files := []string{systemConfigFile}
+	if user := userConfigFile(); user != "" {
+		files = append([]string{user}, files...)
+	}
+	return files
+}
+
+// loadConfig applies the selected context, environment variables and config
+// files to the global flags that were not set on the command line, recording
+// the source of each
+func loadConfig(flags *pflag.FlagSet) error {
+	type fileValue struct {
+		value string
+		path  string
+	}
+	fromFiles := map[string]fileValue{}
+	files := configFiles()
+	for i := len(files) - 1; i >= 0; i-- {
+		config, err := loadConfigFile(files[i])
+		if err != nil {
+			return err
+		}
+		for key, value := range config.Settings {
+			fromFiles[key] = fileValue{value: value, path: files[i]}
+		}
+	}
+	contexts, current, err := loadContexts()
+	if err != nil {
+		return err
+	}
+
+	// A context given with --context overrides the environment, the current
+	// context does not
+	explicitContext := flags.Lookup("context").Changed
+	if explicitContext {
+		current = contextName
+	}
+	fromContext := map[string]string{}
+	var contextErr error
+	if current != "" {
+		if entry, ok := contexts[current]; ok {
+			activeContext = &namedContext{Name: current, connectionContext: entry}
+			fromContext = entry.settings()
+		} else {
+			// Reported after the other settings are applied
+			contextErr = fmt.Errorf("%w: %s", errContextNotFound, current)
+		}
+	}
+
+	for _, key := range configKeys {
+		flag := flags.Lookup(key)
+		if flag.Changed {
+			configSources[key] = "flag --" + key
+			continue
+		}
+
+		value, source := "", "default"
+		contextValue, inContext := fromContext[key]
+		env := configEnv[key]
+		file, inFile := fromFiles[key]
+		switch {
+		case inContext && explicitContext:
+			value, source = contextValue, "context "+current
+		case env != "" && os.Getenv(env) != "":
+			value, source = os.Getenv(env), "env "+env
+		case inContext:
+			value, source = contextValue, "context "+current
+		case inFile:
+			value, source = file.value, "file "+file.path
+		}
+		if source != "default" {
+			if err := flag.Value.Set(value); err != nil {
+				return fmt.Errorf("invalid %s from %s: %w", key, source, err)
+			}
+		}
+		configSources[key] = source
+	}
+	return contextErr
+}
+
+// effectiveSetting returns the value of a setting after loadConfig
+func effectiveSetting(key string) configSetting {
+	source, ok := configSources[key]
+	if !ok {
+		source = "default"
+	}
+	return configSetting{Key: key, Value: rootCmd.PersistentFlags().Lookup(key).Value.String(), Source: source}
+}
+
+func init() {
+	configSetCmd.Flags().BoolVar(&configSystem, "system", false, "write to the system config file "+systemConfigFile)
+
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
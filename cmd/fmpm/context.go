@@ -0,0 +1,334 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Context transports
+const (
+	transportTCP  = "tcp"
+	transportUnix = "unix"
+)
+
+// errContextNotFound is returned when the selected context is not defined
+var errContextNotFound = errors.New("context not found")
+
+var (
+	// Global context flag
+	contextName string
+
+	// activeContext is the context in use, nil if none is selected
+	activeContext *namedContext
+
+	// Context add flags
+	contextTransport string
+	contextAddress   string
+	contextTimeout   int
+	contextSSH       string
+	contextSSHJump   string
+
+	// Context flags
+	contextSystem bool
+
+	// Context command
+	contextCmd = &cobra.Command{
+		Use:   "context",
+		Short: "Manage named FabricManager connection contexts",
+		Long: `Manage named contexts, each holding the transport, address and timeout of a
+FabricManager endpoint, and optionally an SSH host through which it is reached.
+
+Contexts are stored in the fmpm config files. The current context is used when
+no --context flag is given, and the context in use is shown on stderr by every
+command connecting to FabricManager. Flags such as --hostname override the
+values of the context.`,
+	}
+
+	// Context list command
+	contextListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List contexts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			contexts, current, err := loadContexts()
+			if err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(contexts))
+			for name := range contexts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			infos := make([]contextInfo, 0, len(names))
+			table := newResultTable(0, "CURRENT", "NAME", "TRANSPORT", "ADDRESS", "TIMEOUT", "SSH")
+			for _, name := range names {
+				entry := contexts[name]
+				info := contextInfo{
+					Name:      name,
+					Current:   name == current,
+					Transport: entry.Transport,
+					Address:   entry.Address,
+					Timeout:   entry.Timeout,
+					SSH:       entry.SSH,
+					SSHJump:   entry.SSHJump,
+				}
+				infos = append(infos, info)
+
+				marker, timeout := "", ""
+				if info.Current {
+					marker = "*"
+				}
+				if info.Timeout > 0 {
+					timeout = strconv.Itoa(info.Timeout)
+				}
+				table.addRow(marker, name, info.Transport, info.Address, timeout, info.SSH)
+			}
+
+			return printResult(infos, table, func() {
+				if len(infos) == 0 {
+					fmt.Println("No contexts")
+					return
+				}
+				_ = table.write(os.Stdout, outputTable)
+			})
+		},
+	}
+
+	// Context use command
+	contextUseCmd = &cobra.Command{
+		Use:   "use <name>",
+		Short: "Set the current context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			contexts, _, err := loadContexts()
+			if err != nil {
+				return err
+			}
+			if _, ok := contexts[name]; !ok {
+				return fmt.Errorf("%w: %s", errContextNotFound, name)
+			}
+
+			path := configWritePath(contextSystem)
+			config, err := loadConfigFile(path)
+			if err != nil {
+				return err
+			}
+			config.CurrentContext = name
+			if err := config.save(path); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+
+			return printResult(map[string]string{"currentContext": name}, nil, func() {
+				fmt.Printf("Switched to context %s\n", name)
+			})
+		},
+	}
+
+	// Context add command
+	contextAddCmd = &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add or replace a context",
+		Long: `Add a context, or replace the context of the same name.
+
+The transport is tcp, with a host[:port] address, or unix, with the path of a
+UNIX domain socket. It defaults to unix for addresses starting with /.
+
+With --ssh, FabricManager is reached through an SSH tunnel and the address is
+the one seen from the SSH host, usually 127.0.0.1:6666 or the local socket.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if name == "" || strings.ContainsAny(name, " \t\n") {
+				return fmt.Errorf("invalid context name %q", name)
+			}
+			entry := connectionContext{
+				Transport: contextTransport,
+				Address:   contextAddress,
+				Timeout:   contextTimeout,
+				SSH:       contextSSH,
+				SSHJump:   contextSSHJump,
+			}
+			if entry.Transport == "" {
+				entry.Transport = transportTCP
+				if strings.HasPrefix(entry.Address, "/") {
+					entry.Transport = transportUnix
+				}
+			}
+			if err := entry.validate(); err != nil {
+				return err
+			}
+
+			path := configWritePath(contextSystem)
+			config, err := loadConfigFile(path)
+			if err != nil {
+				return err
+			}
+			_, replaced := config.Contexts[name]
+			config.Contexts[name] = entry
+			if err := config.save(path); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+
+			info := contextInfo{Name: name, Current: config.CurrentContext == name,
+				Transport: entry.Transport, Address: entry.Address, Timeout: entry.Timeout, SSH: entry.SSH, SSHJump: entry.SSHJump}
+			return printResult(info, nil, func() {
+				if replaced {
+					fmt.Printf("Replaced context %s in %s\n", name, path)
+				} else {
+					fmt.Printf("Added context %s to %s\n", name, path)
+				}
+			})
+		},
+	}
+
+	// Context delete command
+	contextDeleteCmd = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			path := configWritePath(contextSystem)
+			config, err := loadConfigFile(path)
+			if err != nil {
+				return err
+			}
+			if _, ok := config.Contexts[name]; !ok {
+				return fmt.Errorf("%w in %s: %s", errContextNotFound, path, name)
+			}
+			delete(config.Contexts, name)
+			if config.CurrentContext == name {
+				config.CurrentContext = ""
+			}
+			if err := config.save(path); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+
+			return printResult(map[string]string{"deletedContext": name}, nil, func() {
+				fmt.Printf("Deleted context %s from %s\n", name, path)
+			})
+		},
+	}
+)
+
+// connectionContext is a FabricManager endpoint as stored in config files
+type connectionContext struct {
+	// Transport is tcp or unix
+	Transport string `yaml:"transport"`
+	// Address is host[:port] for tcp, or the socket path for unix
+	Address string `yaml:"address"`
+	// Timeout is the connection timeout in milliseconds, 0 for the default
+	Timeout int `yaml:"timeout,omitempty"`
+	// SSH is the [user@]host[:port] through which FabricManager is reached
+	SSH string `yaml:"ssh,omitempty"`
+	// SSHJump are the comma-separated SSH jump hosts to go through to reach SSH
+	SSHJump string `yaml:"ssh-jump,omitempty"`
+}
+
+func (c connectionContext) validate() error {
+	if c.Transport != transportTCP && c.Transport != transportUnix {
+		return fmt.Errorf("unknown transport %q: must be tcp or unix", c.Transport)
+	}
+	if c.Address == "" {
+		return fmt.Errorf("a context requires an address")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d", c.Timeout)
+	}
+	if c.SSHJump != "" && c.SSH == "" {
+		return fmt.Errorf("SSH jump hosts require an SSH host")
+	}
+	return nil
+}
+
+// settings returns the values of the global flags selected by the context
+func (c connectionContext) settings() map[string]string {
+	settings := map[string]string{}
+	if c.Transport == transportUnix {
+		settings["unix-domain-socket"] = c.Address
+	} else {
+		settings["hostname"] = c.Address
+		settings["unix-domain-socket"] = ""
+	}
+	if c.Timeout > 0 {
+		settings["timeout"] = strconv.Itoa(c.Timeout)
+	}
+	settings["ssh"] = c.SSH
+	settings["ssh-jump"] = c.SSHJump
+	return settings
+}
+
+// namedContext is a context with its name
+type namedContext struct {
+	Name string
+	connectionContext
+}
+
+// contextInfo is the structured form of a context
+type contextInfo struct {
+	Name      string `json:"name" yaml:"name"`
+	Current   bool   `json:"current" yaml:"current"`
+	Transport string `json:"transport" yaml:"transport"`
+	Address   string `json:"address" yaml:"address"`
+	Timeout   int    `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	SSH       string `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	SSHJump   string `json:"sshJump,omitempty" yaml:"sshJump,omitempty"`
+}
+
+// loadContexts returns the contexts of all config files and the current
+// context, the user config file taking precedence over the system one
+func loadContexts() (map[string]connectionContext, string, error) {
+	contexts := map[string]connectionContext{}
+	current := ""
+	files := configFiles()
+	for i := len(files) - 1; i >= 0; i-- {
+		config, err := loadConfigFile(files[i])
+		if err != nil {
+			return nil, "", err
+		}
+		for name, entry := range config.Contexts {
+			contexts[name] = entry
+		}
+		if config.CurrentContext != "" {
+			current = config.CurrentContext
+		}
+	}
+	return contexts, current, nil
+}
+
+// printContextHeader shows the context in use on stderr, so that the output
+// of a command cannot be mistaken for that of another node
+func printContextHeader() {
+	if activeContext == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Context: %s (%s)\n", activeContext.Name, describeAddress(fabricManagerAddress()))
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "named context to connect with (see fmpm context)")
+
+	contextAddCmd.Flags().StringVar(&contextTransport, "transport", "", "transport: tcp or unix")
+	contextAddCmd.Flags().StringVar(&contextAddress, "address", "", "host[:port] for tcp, or the socket path for unix")
+	contextAddCmd.Flags().IntVar(&contextTimeout, "timeout-ms", 0, "connection timeout in milliseconds (default the global --timeout)")
+	contextAddCmd.Flags().StringVar(&contextSSH, "ssh", "", "[user@]host[:port] of the SSH host to reach FabricManager through")
+	contextAddCmd.Flags().StringVar(&contextSSHJump, "ssh-jump", "", "comma-separated SSH jump hosts to go through to reach --ssh")
+	for _, cmd := range []*cobra.Command{contextUseCmd, contextAddCmd, contextDeleteCmd} {
+		cmd.Flags().BoolVar(&contextSystem, "system", false, "change the system config file "+systemConfigFile)
+	}
+
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextAddCmd)
+	contextCmd.AddCommand(contextDeleteCmd)
+	rootCmd.AddCommand(contextCmd)
+}
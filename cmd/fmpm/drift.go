@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Drift command
+	driftCmd = &cobra.Command{
+		Use:   "drift",
+		Short: "Compare the partition tables of hosts",
+		Long: `Fingerprint the supported partition table of every host given with --hosts or
+--inventory by partition ID, size and GPU physical IDs, ignoring UUIDs, PCI bus
+IDs and active state. Hosts are grouped by fingerprint, the largest group being
+the majority, and the partitions of every other host that differ from the
+majority are listed. Exits with an error when drift is found or a host cannot
+be read.`,
+		Annotations: map[string]string{fleetAnnotation: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hosts, err := fleetHosts()
+			if err != nil {
+				return err
+			}
+			if len(hosts) == 0 {
+				return fmt.Errorf("drift requires --hosts or --inventory")
+			}
+			cmd.SilenceUsage = true
+
+			report, results := newCLIFleet(hosts).DetectDrift()
+			result := driftResult{DriftReport: report, Errors: map[string]errorDetail{}}
+			for _, hostResult := range results {
+				if hostResult.Err != nil {
+					result.Errors[hostResult.Host] = newErrorDetail(hostResult.Err)
+				}
+			}
+
+			if err := printResult(result, driftTable(result, results), func() { printDrift(result) }); err != nil {
+				return err
+			}
+
+			switch {
+			case report.HasDrift():
+				return &reportedError{err: fmt.Errorf("partition table drift found on %d of %d hosts", len(report.Outliers), len(hosts))}
+			case len(result.Errors) > 0:
+				return &reportedError{err: fmt.Errorf("%d of %d hosts failed", len(result.Errors), len(hosts))}
+			}
+			return nil
+		},
+	}
+)
+
+// driftResult is a drift report with the hosts that could not be read
+type driftResult struct {
+	*fabricmanager.DriftReport `yaml:",inline"`
+	Errors                     map[string]errorDetail `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// outlier returns the drift of a host, nil if it is not an outlier
+func (r driftResult) outlier(host string) *fabricmanager.HostDrift {
+	for i := range r.Outliers {
+		if r.Outliers[i].Host == host {
+			return &r.Outliers[i]
+		}
+	}
+	return nil
+}
+
+// formatPartitionDrift describes how a partition differs from the majority
+func formatPartitionDrift(drift fabricmanager.PartitionDrift) string {
+	switch drift.Kind {
+	case fabricmanager.DriftMissing:
+		return fmt.Sprintf("partition %d missing (GPUs %s)", drift.ID, joinIDs(drift.ExpectedGPUs))
+	case fabricmanager.DriftExtra:
+		return fmt.Sprintf("partition %d extra (GPUs %s)", drift.ID, joinIDs(drift.ActualGPUs))
+	}
+	return fmt.Sprintf("partition %d has GPUs %s instead of %s", drift.ID, joinIDs(drift.ActualGPUs), joinIDs(drift.ExpectedGPUs))
+}
+
+// driftTable is the tabular view of a drift report, one row per host
+func driftTable(result driftResult, results []fabricmanager.HostResult) *resultTable {
+	group := make(map[string]int)
+	for i, g := range result.Groups {
+		for _, host := range g.Hosts {
+			group[host] = i + 1
+		}
+	}
+
+	table := newResultTable(1, "HOST", "GROUP", "STATUS", "DIFFERENCES", "FINGERPRINT")
+	for _, hostResult := range results {
+		host := hostResult.Host
+		if detail, ok := result.Errors[host]; ok {
+			table.addRow(host, "", "unreachable", detail.Message, "")
+			continue
+		}
+
+		status, differences := "majority", []string{}
+		if drift := result.outlier(host); drift != nil {
+			status = "outlier"
+			for _, partition := range drift.Partitions {
+				differences = append(differences, formatPartitionDrift(partition))
+			}
+		}
+		table.addRow(host, fmt.Sprint(group[host]), status, strings.Join(differences, "; "),
+			result.Groups[group[host]-1].Fingerprint)
+	}
+	return table
+}
+
+func printDrift(result driftResult) {
+	hosts := len(result.Errors)
+	for _, group := range result.Groups {
+		hosts += len(group.Hosts)
+	}
+	fmt.Printf("%d host(s) in %d group(s), %d outlier(s)\n", hosts, len(result.Groups), len(result.Outliers))
+
+	for i, group := range result.Groups {
+		name := fmt.Sprintf("Group %d", i+1)
+		if i == 0 {
+			name = "Majority"
+		}
+		fmt.Printf("\n%s (%s, %d host(s)): %s\n", name, group.Fingerprint, len(group.Hosts), strings.Join(group.Hosts, ", "))
+	}
+
+	if len(result.Outliers) > 0 {
+		fmt.Println("\nOutliers:")
+		for _, drift := range result.Outliers {
+			fmt.Printf("  %s:\n", drift.Host)
+			for _, partition := range drift.Partitions {
+				fmt.Printf("    %s\n", formatPartitionDrift(partition))
+			}
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		fmt.Println("\nUnreachable:")
+		hosts := make([]string, 0, len(result.Errors))
+		for host := range result.Errors {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			fmt.Printf("  %s: %s\n", host, result.Errors[host].Message)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+}
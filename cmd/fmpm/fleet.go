@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+// fleetAnnotation marks the commands that can run across hosts
+const fleetAnnotation = "fleet"
+
+var (
+	// Fleet flags
+	fleetHostList    string
+	fleetInventory   string
+	fleetParallelism int
+	fleetHostTimeout time.Duration
+)
+
+// queryResult is the result of a read command on one FabricManager
+type queryResult struct {
+	data  any
+	table *resultTable
+	text  func()
+	// failure is returned after the result is printed, see reportedError
+	failure error
+}
+
+// readQuery computes the result of a read command. host is empty when
+// querying the FabricManager selected by the global flags.
+type readQuery func(host string, pm fabricmanager.PartitionManager) (*queryResult, error)
+
+// fleetHostResult is the result of a read command on one host of a fleet
+type fleetHostResult struct {
+	Result     any          `json:"result,omitempty" yaml:"result,omitempty"`
+	Error      *errorDetail `json:"error,omitempty" yaml:"error,omitempty"`
+	DurationMs int64        `json:"durationMs" yaml:"durationMs"`
+}
+
+// fleetHosts returns the hosts selected by --hosts and --inventory
+func fleetHosts() ([]string, error) {
+	hosts := fabricmanager.ParseHosts(fleetHostList)
+	if fleetInventory != "" {
+		inventory, err := fabricmanager.LoadInventory(fleetInventory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inventory: %w", err)
+		}
+		hosts = fabricmanager.ParseHosts(strings.Join(append(hosts, inventory...), ","))
+	}
+	return hosts, nil
+}
+
+// newCLIFleet creates a fleet of hosts reached with the global --port and
+// --timeout, bounded by --parallel and --host-timeout
+func newCLIFleet(hosts []string) *fabricmanager.Fleet {
+	fleet := fabricmanager.NewFleet(hosts, timeoutMs)
+	fleet.Parallelism = fleetParallelism
+	fleet.Timeout = fleetHostTimeout
+	fleet.Connect = func(host string) (fabricmanager.HostConnection, error) {
+		client, err := fabricmanager.Connect(fabricmanager.HostAddress(host, port), timeoutMs)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	return fleet
+}
+
+// runQuery prints the result of a read command on the selected FabricManager,
+// or on every host given with --hosts and --inventory
+func runQuery(cmd *cobra.Command, query readQuery) error {
+	hosts, err := fleetHosts()
+	if err != nil {
+		return err
+	}
+	// Arguments are valid, failures from here on are not usage errors
+	cmd.SilenceUsage = true
+	if len(hosts) > 0 {
+		return runFleetQuery(hosts, query)
+	}
+
+	client, err := connectToFabricManager()
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
+	result, err := query("", client)
+	if err != nil {
+		return err
+	}
+	if err := printResult(result.data, result.table, result.text); err != nil {
+		return err
+	}
+	if result.failure != nil {
+		return &reportedError{err: result.failure}
+	}
+	return nil
+}
+
+// runFleetQuery runs a read command concurrently on hosts and prints the
+// results keyed by host. Failing hosts are reported in their own entry.
+func runFleetQuery(hosts []string, query readQuery) error {
+	results := newCLIFleet(hosts).Run(func(host string, pm fabricmanager.PartitionManager) (any, error) {
+		result, err := query(host, pm)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+
+	data := make(map[string]fleetHostResult, len(results))
+	failed := 0
+	for _, result := range results {
+		entry := fleetHostResult{DurationMs: result.Duration.Milliseconds()}
+		if query := hostQuery(result); query != nil {
+			entry.Result = query.data
+		}
+		if err := hostError(result); err != nil {
+			detail := newErrorDetail(err)
+			entry.Error = &detail
+			failed++
+		}
+		data[result.Host] = entry
+	}
+
+	if err := printResult(data, fleetTable(results), func() {
+		for i, result := range results {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("=== %s ===\n", result.Host)
+			if query := hostQuery(result); query != nil {
+				query.text()
+			}
+			if entry := data[result.Host]; entry.Error != nil {
+				fmt.Printf("Error: %s\n", entry.Error.Message)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return &reportedError{err: fmt.Errorf("%d of %d hosts failed", failed, len(results))}
+	}
+	return nil
+}
+
+// hostQuery returns the result of a read command on a host, nil if it failed
+func hostQuery(result fabricmanager.HostResult) *queryResult {
+	query, _ := result.Value.(*queryResult)
+	return query
+}
+
+// hostError returns the error of a host, including failures reported with a result
+func hostError(result fabricmanager.HostResult) error {
+	if result.Err != nil {
+		return result.Err
+	}
+	if query := hostQuery(result); query != nil {
+		return query.failure
+	}
+	return nil
+}
+
+// fleetTable merges the tables of the hosts, adding HOST and ERROR columns.
+// It returns nil if the command has no tabular view.
+func fleetTable(results []fabricmanager.HostResult) *resultTable {
+	var header []string
+	wide := 0
+	for _, result := range results {
+		if query := hostQuery(result); query != nil {
+			if query.table == nil {
+				return nil
+			}
+			header, wide = query.table.header, query.table.wide
+			break
+		}
+	}
+
+	narrow := len(header) - wide
+	join := func(host string, values []string, errText string) []string {
+		row := append([]string{host}, values[:narrow]...)
+		row = append(row, errText)
+		return append(row, values[narrow:]...)
+	}
+
+	merged := newResultTable(wide, join("HOST", header, "ERROR")...)
+	for _, result := range results {
+		errText := ""
+		if err := hostError(result); err != nil {
+			errText = err.Error()
+		}
+		query := hostQuery(result)
+		if query == nil || len(query.table.rows) == 0 {
+			if errText != "" {
+				merged.addRow(join(result.Host, make([]string, len(header)), errText)...)
+			}
+			continue
+		}
+		for _, row := range query.table.rows {
+			merged.addRow(join(result.Host, row, errText)...)
+		}
+	}
+	return merged
+}
+
+// fleetCommands returns the names of the commands supporting --hosts
+func fleetCommands(root *cobra.Command) []string {
+	var names []string
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			if sub.Annotations[fleetAnnotation] != "" {
+				names = append(names, strings.TrimPrefix(sub.CommandPath(), root.Name()+" "))
+			}
+			walk(sub)
+		}
+	}
+	walk(root)
+	sort.Strings(names)
+	return names
+}
+
+// checkFleetFlags rejects --hosts and --inventory for commands that cannot
+// run across hosts
+func checkFleetFlags(cmd *cobra.Command) error {
+	if fleetHostList == "" && fleetInventory == "" {
+		return nil
+	}
+	if cmd.Annotations[fleetAnnotation] == "" {
+		return fmt.Errorf("--hosts and --inventory are only supported by %s", strings.Join(fleetCommands(cmd.Root()), ", "))
+	}
+	if fleetParallelism < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	// Fleet hosts are reached directly, ignoring the SSH host of the context
+	// and config files
+	if cmd.Root().PersistentFlags().Lookup("ssh").Changed {
+		return fmt.Errorf("--ssh cannot be combined with --hosts and --inventory")
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&fleetHostList, "hosts", "", "run a command on these comma-separated hosts")
+	rootCmd.PersistentFlags().StringVar(&fleetInventory, "inventory", "", "run a command on the hosts listed in this file, one per line")
+	rootCmd.PersistentFlags().IntVar(&fleetParallelism, "parallel", fabricmanager.DefaultFleetParallelism, "maximum number of hosts queried at once")
+	rootCmd.PersistentFlags().DurationVar(&fleetHostTimeout, "host-timeout", 30*time.Second, "time limit for each host, 0 for none")
+
+	// Read-only commands can run across hosts
+	for _, c := range []*cobra.Command{listCmd, treeCmd, capacityCmd, verifyCmd, diffCmd, nvlinkFailedCmd, unsupportedCmd} {
+		if c.Annotations == nil {
+			c.Annotations = map[string]string{}
+		}
+		c.Annotations[fleetAnnotation] = "true"
+	}
+}
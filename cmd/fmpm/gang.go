@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gangGPUs int
+
+	// Gang activate command
+	gangActivateCmd = &cobra.Command{
+		Use:   "gang-activate",
+		Short: "Activate a partition on every host, or on none",
+		Long: `Select and activate a partition with the requested number of GPUs on every
+host given with --hosts or --inventory, in parallel, as allocate would on each.
+
+If any host fails, the partitions activated on the other hosts are deactivated,
+so that a multi-node job gets all of its hosts or none. The report lists the
+status, partition and GPU UUIDs of every host.`,
+		Annotations: map[string]string{fleetAnnotation: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if gangGPUs <= 0 {
+				return fmt.Errorf("--gpus must be a positive number")
+			}
+			hosts, err := fleetHosts()
+			if err != nil {
+				return err
+			}
+			if len(hosts) == 0 {
+				return fmt.Errorf("gang-activate requires --hosts or --inventory")
+			}
+			cmd.SilenceUsage = true
+
+			report, gangErr := newCLIFleet(hosts).GangActivate(gangGPUs)
+			if err := printResult(report, gangTable(report), func() { printGang(report) }); err != nil {
+				return err
+			}
+			if gangErr != nil {
+				return &reportedError{err: gangErr}
+			}
+			return nil
+		},
+	}
+)
+
+// gangTable is the tabular view of a gang activation, one row per host
+func gangTable(report *fabricmanager.GangReport) *resultTable {
+	table := newResultTable(1, "HOST", "STATUS", "PARTITION", "ERROR", "GPU UUIDS")
+	for _, host := range report.Hosts {
+		partition, uuids := "", ""
+		if host.Allocation != nil {
+			partition = strconv.FormatUint(uint64(host.Allocation.PartitionID), 10)
+			uuids = host.Allocation.VisibleDevices()
+		}
+		table.addRow(host.Host, string(host.Status), partition, host.Error, uuids)
+	}
+	return table
+}
+
+func printGang(report *fabricmanager.GangReport) {
+	leftActive := 0
+	for _, host := range report.Hosts {
+		if host.Status == fabricmanager.StepRollbackFailed {
+			leftActive++
+		}
+	}
+	switch {
+	case report.Committed:
+		fmt.Printf("Activated a %d-GPU partition on %d host(s)\n", report.NumGPUs, len(report.Hosts))
+	case leftActive > 0:
+		fmt.Printf("Failed to activate a %d-GPU partition on every host, %d host(s) could not be rolled back\n", report.NumGPUs, leftActive)
+	default:
+		fmt.Printf("Failed to activate a %d-GPU partition on every host, all hosts rolled back\n", report.NumGPUs)
+	}
+
+	for _, host := range report.Hosts {
+		fmt.Printf("  %s: %s", host.Host, host.Status)
+		if host.Allocation != nil {
+			fmt.Printf(", partition %d, GPUs %s", host.Allocation.PartitionID, strings.Join(host.Allocation.GPUUUIDs, ", "))
+		}
+		if host.Error != "" {
+			fmt.Printf(" (%s)", host.Error)
+		}
+		fmt.Println()
+	}
+}
+
+func init() {
+	gangActivateCmd.Flags().IntVar(&gangGPUs, "gpus", 0, "number of GPUs of the partition to activate on each host")
+
+	rootCmd.AddCommand(gangActivateCmd)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Lease flags
+	leaseFile         string
+	leaseGPUs         int
+	leaseTTL          time.Duration
+	leaseOwner        string
+	leaseWatch        bool
+	leaseReapInterval time.Duration
+
+	// Lease command
+	leaseCmd = &cobra.Command{
+		Use:   "lease",
+		Short: "Lease partitions for a limited time",
+		Long: `Lease partitions to an owner for a limited time. A leased partition is
+deactivated when its lease is released or once it has expired.
+
+Nothing deactivates an expired lease by itself: it is reaped by "lease
+acquire", "lease release", "lease reap" and "fmpm agent". Run the agent or
+"lease reap --watch" as a service to reap leases once they expire. "lease renew"
+and "lease list" only read and write the lease file and never reap.`,
+	}
+
+	// Lease acquire command
+	leaseAcquireCmd = &cobra.Command{
+		Use:   "acquire",
+		Short: "Activate a partition of the requested size and lease it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if leaseGPUs <= 0 {
+				return fmt.Errorf("--gpus must be a positive number of GPUs")
+			}
+
+			client, err := connectToFabricManager()
+			if err != nil {
+				return err
+			}
+			defer client.Disconnect()
+
+			store := fabricmanager.NewLeaseStore(leaseFile)
+			store.OnReapError = func(err error) {
+				fmt.Fprintf(os.Stderr, "Warning: failed to reap expired leases: %v\n", err)
+			}
+			lease, err := store.Acquire(client, leaseGPUs, leaseTTL, leaseOwner)
+			if err != nil {
+				return fmt.Errorf("failed to acquire lease: %w", err)
+			}
+
+			return printResult(lease, leaseTable([]fabricmanager.Lease{*lease}), func() {
+				fmt.Printf("Leased partition %d to %s until %s\n", lease.PartitionID, lease.Owner, lease.ExpiresAt.Format(time.RFC3339))
+				fmt.Printf("CUDA_VISIBLE_DEVICES=%s\n", (&fabricmanager.Allocation{GPUUUIDs: lease.GPUUUIDs}).VisibleDevices())
+			})
+		},
+	}
+
+	// Lease renew command
+	leaseRenewCmd = &cobra.Command{
+		Use:   "renew <partition-id>",
+		Short: "Extend a lease",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseLeasePartitionID(args[0])
+			if err != nil {
+				return err
+			}
+
+			lease, err := fabricmanager.NewLeaseStore(leaseFile).Renew(id, leaseOwner, leaseTTL)
+			if err != nil {
+				return fmt.Errorf("failed to renew lease: %w", err)
+			}
+
+			return printResult(lease, leaseTable([]fabricmanager.Lease{*lease}), func() {
+				fmt.Printf("Renewed lease of partition %d until %s\n", lease.PartitionID, lease.ExpiresAt.Format(time.RFC3339))
+			})
+		},
+	}
+
+	// Lease release command
+	leaseReleaseCmd = &cobra.Command{
+		Use:   "release <partition-id>",
+		Short: "Release a lease and deactivate its partition",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseLeasePartitionID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client, err := connectToFabricManager()
+			if err != nil {
+				return err
+			}
+			defer client.Disconnect()
+
+			// The lease is released before reaping, which would otherwise
+			// remove it once expired
+			store := fabricmanager.NewLeaseStore(leaseFile)
+			if err := store.Release(client, id, leaseOwner); err != nil {
+				return fmt.Errorf("failed to release lease: %w", err)
+			}
+			reapLeases(store, client)
+
+			return printResult(actionResult{Action: "release", PartitionIDs: []uint32{id}}, nil, func() {
+				fmt.Printf("Released partition %d\n", id)
+			})
+		},
+	}
+
+	// Lease reap command
+	leaseReapCmd = &cobra.Command{
+		Use:   "reap",
+		Short: "Deactivate the partitions of expired leases",
+		Long: `Deactivate the partitions of expired leases. With --watch, expired leases are
+reaped every --interval until SIGINT or SIGTERM, taking the host lock only while
+reaping.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if leaseWatch {
+				if leaseReapInterval <= 0 {
+					return fmt.Errorf("--interval must be a positive duration")
+				}
+				cmd.SilenceUsage = true
+				return watchLeases(fabricmanager.NewLeaseStore(leaseFile))
+			}
+			return withHostLock(reapExpiredLeases)
+		},
+	}
+
+	// Lease list command
+	leaseListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List leases",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			leases, err := fabricmanager.NewLeaseStore(leaseFile).List()
+			if err != nil {
+				return fmt.Errorf("failed to read leases: %w", err)
+			}
+
+			return printResult(leases, leaseTable(leases), func() {
+				if len(leases) == 0 {
+					fmt.Println("No leases")
+					return
+				}
+				for _, lease := range leases {
+					fmt.Printf("Partition %d: %s, %s\n", lease.PartitionID, lease.Owner, formatLeaseExpiry(lease, time.Now()))
+				}
+			})
+		},
+	}
+)
+
+// leaseTable is the tabular view of leases
+func leaseTable(leases []fabricmanager.Lease) *resultTable {
+	table := newResultTable(1, "PARTITION", "OWNER", "ACQUIRED", "EXPIRES", "STATUS", "GPU UUIDS")
+	now := time.Now()
+	for _, lease := range leases {
+		status := "valid"
+		if lease.Expired(now) {
+			status = "expired"
+		}
+		table.addRow(strconv.FormatUint(uint64(lease.PartitionID), 10), lease.Owner,
+			lease.AcquiredAt.Format(time.RFC3339), lease.ExpiresAt.Format(time.RFC3339), status,
+			strings.Join(lease.GPUUUIDs, ","))
+	}
+	return table
+}
+
+// defaultLeaseOwner returns the name of the user running fmpm
+func defaultLeaseOwner() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+func parseLeasePartitionID(arg string) (uint32, error) {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid partition ID: %w", err)
+	}
+	return uint32(id), nil
+}
+
+// reapLeases deactivates the partitions of expired leases, logging failures
+func reapLeases(store *fabricmanager.LeaseStore, pm fabricmanager.PartitionManager) {
+	reaped, err := store.Reap(pm)
+	for _, lease := range reaped {
+		fmt.Fprintf(os.Stderr, "Reaped expired lease of partition %d held by %s\n", lease.PartitionID, lease.Owner)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to reap expired leases: %v\n", err)
+	}
+}
+
+// reapExpiredLeases deactivates the partitions of expired leases and prints
+// them
+func reapExpiredLeases() error {
+	client, err := connectToFabricManager()
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect()
+
+	reaped, err := fabricmanager.NewLeaseStore(leaseFile).Reap(client)
+	if reaped == nil {
+		reaped = []fabricmanager.Lease{}
+	}
+	if err != nil {
+		return &detailedError{err: fmt.Errorf("failed to reap leases: %w", err), details: reaped}
+	}
+
+	return printResult(reaped, leaseTable(reaped), func() {
+		for _, lease := range reaped {
+			fmt.Printf("Reaped lease of partition %d held by %s\n", lease.PartitionID, lease.Owner)
+		}
+		if len(reaped) == 0 {
+			fmt.Println("No expired leases")
+		}
+	})
+}
+
+// watchLeases reaps expired leases every --interval until SIGINT or SIGTERM.
+// FabricManager is only connected and the host lock only taken when a lease
+// has expired.
+func watchLeases(store *fabricmanager.LeaseStore) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	log.Printf("Reaping expired leases of %s every %s", leaseFile, leaseReapInterval)
+
+	ticker := time.NewTicker(leaseReapInterval)
+	defer ticker.Stop()
+	for {
+		if err := reapWatchedLeases(store); err != nil {
+			log.Printf("Warning: failed to reap expired leases: %v", err)
+		}
+
+		select {
+		case sig := <-signals:
+			log.Printf("Received %s, shutting down", sig)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapWatchedLeases reaps the expired leases of store under the host lock
+func reapWatchedLeases(store *fabricmanager.LeaseStore) error {
+	leases, err := store.List()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expired := false
+	for _, lease := range leases {
+		expired = expired || lease.Expired(now)
+	}
+	if !expired {
+		return nil
+	}
+
+	return withHostLock(func() error {
+		client, err := connectToFabricManager()
+		if err != nil {
+			return err
+		}
+		defer client.Disconnect()
+
+		reapLeases(store, client)
+		return nil
+	})
+}
+
+// formatLeaseExpiry describes when a lease expires
+func formatLeaseExpiry(lease fabricmanager.Lease, now time.Time) string {
+	if lease.Expired(now) {
+		return fmt.Sprintf("expired at %s", lease.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("expires at %s (in %s)", lease.ExpiresAt.Local().Format(time.RFC3339),
+		lease.ExpiresAt.Sub(now).Round(time.Second))
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&leaseFile, "lease-file", "/var/lib/fmpm/leases.json", "partition lease file")
+
+	leaseAcquireCmd.Flags().IntVar(&leaseGPUs, "gpus", 0, "number of GPUs of the partition to lease")
+	for _, c := range []*cobra.Command{leaseAcquireCmd, leaseRenewCmd} {
+		c.Flags().DurationVar(&leaseTTL, "ttl", time.Hour, "lease duration")
+	}
+	for _, c := range []*cobra.Command{leaseAcquireCmd, leaseRenewCmd, leaseReleaseCmd} {
+		c.Flags().StringVar(&leaseOwner, "owner", defaultLeaseOwner(), "lease owner")
+	}
+
+	leaseReapCmd.Flags().BoolVar(&leaseWatch, "watch", false, "keep reaping expired leases every --interval")
+	leaseReapCmd.Flags().DurationVar(&leaseReapInterval, "interval", time.Minute, "reap interval of --watch")
+
+	leaseCmd.AddCommand(leaseAcquireCmd)
+	leaseCmd.AddCommand(leaseRenewCmd)
+	leaseCmd.AddCommand(leaseReleaseCmd)
+	leaseCmd.AddCommand(leaseReapCmd)
+	leaseCmd.AddCommand(leaseListCmd)
+	rootCmd.AddCommand(leaseCmd)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Host lock flags
+	lockFile    string
+	lockTimeout time.Duration
+)
+
+// withHostLock runs fn while holding the host lock, reporting waits and stale holders on stderr
+func withHostLock(fn func() error) error {
+	lock := fabricmanager.NewHostLock(lockFile, lockTimeout)
+	lock.OnWait = func(holder *fabricmanager.HostLockHolder) {
+		if holder != nil {
+			fmt.Fprintf(os.Stderr, "Waiting up to %s for host lock held by %s\n", lockTimeout, holder)
+		} else {
+			fmt.Fprintf(os.Stderr, "Waiting up to %s for host lock %s\n", lockTimeout, lockFile)
+		}
+	}
+	lock.OnStale = func(holder fabricmanager.HostLockHolder) {
+		fmt.Fprintf(os.Stderr, "Warning: recovered stale host lock left by %s\n", holder)
+	}
+	return lock.Do(fn)
+}
+
+// lockedRunE wraps a command so that it runs under the host lock
+func lockedRunE(runE func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		return withHostLock(func() error {
+			return runE(cmd, args)
+		})
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&lockFile, "lock-file", fabricmanager.DefaultHostLockPath, "host lock file serializing mutating commands")
+	rootCmd.PersistentFlags().DurationVar(&lockTimeout, "lock-timeout", 30*time.Second, "how long mutating commands wait for the host lock")
+
+	// Mutating commands run under the host lock, read-only commands stay lock-free.
+	// "lease reap" takes the lock itself, so that --watch only holds it while
+	// reaping.
+	for _, c := range []*cobra.Command{
+		activateCmd,
+		deactivateCmd,
+		setActivatedCmd,
+		allocateCmd,
+		switchCmd,
+		applyCmd,
+		stateRestoreCmd,
+		leaseAcquireCmd,
+		leaseReleaseCmd,
+	} {
+		c.RunE = lockedRunE(c.RunE)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NVIDIA/go-fabricmanager"
 	"github.com/spf13/cobra"
@@ -14,9 +17,45 @@ import (
 var (
 	// Global flags
 	hostname         string
+	port             int
 	unixDomainSocket string
 	timeoutMs        int = 5000
 
+	// List flags
+	listAvailable   bool
+	listActive      bool
+	listInactive    bool
+	listGPUs        int
+	listContainsGPU string
+	listDegraded    bool
+	listFilter      string
+	listSortBy      string
+	listIDsOnly     bool
+
+	// Allocate flags
+	allocateGPUs int
+
+	// Capacity flags
+	capacityJSON bool
+
+	// Switch flags
+	switchFrom string
+	switchTo   string
+
+	// Desired state flags
+	desiredStateFile string
+	desiredPrune     bool
+
+	// State flags
+	stateFile string
+	stateWait time.Duration
+
+	// NVLink failed devices flags
+	nvlinkImpact bool
+
+	// Unsupported partitions flags
+	unsupportedExplain bool
+
 	// Root command
 	rootCmd = &cobra.Command{
 		Use:   "fmpm",
@@ -25,10 +64,29 @@ var (
 for NVIDIA Fabric Manager's Shared NVSwitch feature.
 
 Management operations include listing, activating, deactivating partitions, etc.`,
+		SilenceErrors: true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(cmd.Root().PersistentFlags()); err != nil {
+				// The context commands must keep working to fix a missing context
+				if !errors.Is(err, errContextNotFound) || cmd.Parent() != contextCmd {
+					return err
+				}
+			}
+			if capacityJSON {
+				outputFormat = outputJSON
+			}
+			if err := validateOutputFormat(); err != nil {
+				return err
+			}
+			// Structured errors replace the usage text
+			cmd.SilenceUsage = structuredOutput()
+			if err := checkFleetFlags(cmd); err != nil {
+				return err
+			}
+
 			// Initialize FabricManager library
 			if err := fabricmanager.Init(); err != nil {
-				return fmt.Errorf("failed to initialize FabricManager: %v", err)
+				return fmt.Errorf("failed to initialize FabricManager: %w", err)
 			}
 			return nil
 		},
@@ -44,49 +102,214 @@ Management operations include listing, activating, deactivating partitions, etc.
 	listCmd = &cobra.Command{
 		Use:   "list",
 		Short: "List all supported fabric partitions",
-		Long:  "List all supported fabric partitions with their current status and GPU information",
+		Long: `List all supported fabric partitions with their current status and GPU information.
+
+The selection flags are combined with AND. --filter accepts an expression
+combining conditions with &&, || and !, for example:
+
+  fmpm list --filter 'inactive && gpus >= 4 && !degraded'
+  fmpm list --filter 'gpu = GPU-1234abcd || gpu = 0000:1b:00.0'
+
+Conditions are active, inactive, degraded, gpu = <uuid|busid|physid>, and
+comparisons of id, gpus, bandwidth (effective MB/s) and degradation (percent).`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client, err := connectToFabricManager()
-			if err != nil {
-				return err
+			if listActive && listInactive {
+				return fmt.Errorf("--active and --inactive are mutually exclusive")
 			}
-			defer client.Disconnect()
-
-			partitions, err := client.GetSupportedPartitions()
-			if err != nil {
-				return fmt.Errorf("failed to get partitions: %v", err)
+			var filters []fabricmanager.PartitionFilter
+			if listActive || listInactive {
+				filters = append(filters, fabricmanager.IsActiveFilter(listActive))
 			}
-
-			if len(partitions) == 0 {
-				fmt.Println("No partitions found")
-				return nil
+			if listGPUs > 0 {
+				filters = append(filters, fabricmanager.NumGPUsFilter(listGPUs))
+			}
+			if listContainsGPU != "" {
+				filters = append(filters, fabricmanager.ContainsGPUFilter(listContainsGPU))
+			}
+			if listDegraded {
+				filters = append(filters, fabricmanager.DegradedFilter())
+			}
+			if listFilter != "" {
+				filter, err := fabricmanager.ParseFilter(listFilter)
+				if err != nil {
+					return fmt.Errorf("invalid filter: %w", err)
+				}
+				filters = append(filters, filter)
 			}
 
-			fmt.Printf("Found %d partition(s):\n\n", len(partitions))
-			for _, partition := range partitions {
-				status := "Inactive"
-				if partition.IsActive {
-					status = "Active"
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				partitions, err := client.GetSupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get partitions: %w", err)
 				}
-				fmt.Printf("Partition ID: %d\n", partition.ID)
-				fmt.Printf("  Status: %s\n", status)
-				fmt.Printf("  GPUs: %d\n", partition.NumGPUs)
-
-				if len(partition.GPUs) > 0 {
-					fmt.Printf("  GPU Details:\n")
-					for _, gpu := range partition.GPUs {
-						fmt.Printf("    Physical ID: %d\n", gpu.PhysicalID)
-						fmt.Printf("    UUID: %s\n", gpu.UUID)
-						fmt.Printf("    PCI Bus ID: %s\n", gpu.PCIBusID)
-						fmt.Printf("    NVLinks Available: %d/%d\n", gpu.NumNvLinksAvailable, gpu.MaxNumNvLinks)
-						fmt.Printf("    Line Rate: %d MB/s\n", gpu.NvlinkLineRateMBps)
-						fmt.Println()
+
+				if listAvailable {
+					partitions = fabricmanager.AvailablePartitions(partitions)
+				}
+				partitions = fabricmanager.FilterPartitions(partitions, fabricmanager.AllOf(filters...))
+				if listSortBy != "" {
+					if partitions, err = fabricmanager.SortPartitions(partitions, listSortBy); err != nil {
+						return nil, err
 					}
 				}
-				fmt.Println()
-			}
 
-			return nil
+				if listIDsOnly {
+					ids := make([]uint32, 0, len(partitions))
+					table := newResultTable(0, "ID")
+					for _, partition := range partitions {
+						ids = append(ids, partition.ID)
+						table.addRow(strconv.FormatUint(uint64(partition.ID), 10))
+					}
+					return &queryResult{data: ids, table: table, text: func() {
+						for _, id := range ids {
+							fmt.Println(id)
+						}
+					}}, nil
+				}
+
+				// Leases are only known for the local FabricManager
+				leases := make(map[uint32]fabricmanager.Lease)
+				if host == "" {
+					if all, err := fabricmanager.NewLeaseStore(leaseFile).List(); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: failed to read leases: %v\n", err)
+					} else {
+						for _, lease := range all {
+							leases[lease.PartitionID] = lease
+						}
+					}
+				}
+
+				items := make([]listItem, 0, len(partitions))
+				for _, partition := range partitions {
+					item := listItem{Partition: partition}
+					if lease, ok := leases[partition.ID]; ok {
+						item.Lease = &lease
+					}
+					items = append(items, item)
+				}
+
+				return &queryResult{data: items, table: partitionTable(items), text: func() { printPartitions(items) }}, nil
+			})
+		},
+	}
+
+	// Tree command
+	treeCmd = &cobra.Command{
+		Use:   "tree",
+		Short: "Show the fabric partition hierarchy",
+		Long:  "Show the supported fabric partitions as a containment tree with the active or blocked state of each partition",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				partitions, err := client.GetSupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get partitions: %w", err)
+				}
+				roots := fabricmanager.BuildPartitionTree(partitions)
+
+				table := newResultTable(0, "ID", "PARENT", "DEPTH", "GPUS", "PHYSICAL IDS", "STATE", "BLOCKED BY")
+				var addNode func(node *fabricmanager.PartitionNode, parent string, depth int)
+				addNode = func(node *fabricmanager.PartitionNode, parent string, depth int) {
+					id := strconv.FormatUint(uint64(node.Partition.ID), 10)
+					table.addRow(id, parent, strconv.Itoa(depth), strconv.FormatUint(uint64(node.Partition.NumGPUs), 10),
+						joinIDs(physicalIDs(node.Partition)), string(node.State), joinIDs(node.BlockedBy))
+					for _, child := range node.Children {
+						addNode(child, id, depth+1)
+					}
+				}
+				for _, root := range roots {
+					addNode(root, "", 0)
+				}
+
+				return &queryResult{data: roots, table: table, text: func() {
+					if len(roots) == 0 {
+						fmt.Println("No partitions found")
+						return
+					}
+					for _, root := range roots {
+						printPartitionNode(root, "", "")
+					}
+				}}, nil
+			})
+		},
+	}
+
+	// Capacity command
+	capacityCmd = &cobra.Command{
+		Use:   "capacity",
+		Short: "Show free GPU capacity and fragmentation",
+		Long:  "Show how many partitions of each size can still be activated together, the largest activatable partition and a fragmentation score",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				partitions, err := client.GetSupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get partitions: %w", err)
+				}
+				report := fabricmanager.NewCapacityReport(partitions)
+
+				table := newResultTable(0, "GPUS", "SUPPORTED", "ACTIVATABLE")
+				for _, size := range report.Sizes {
+					table.addRow(strconv.Itoa(size.NumGPUs), strconv.Itoa(size.Supported), strconv.Itoa(size.Activatable))
+				}
+
+				return &queryResult{data: report, table: table, text: func() {
+					fmt.Printf("Free GPUs: %d of %d\n", report.FreeGPUs, report.TotalGPUs)
+					active := joinIDs(report.ActivePartitions)
+					if active == "" {
+						active = "none"
+					}
+					fmt.Printf("Active partitions: %s\n", active)
+					fmt.Printf("Largest activatable partition: %d GPUs\n", report.LargestActivatable)
+					fmt.Printf("Fragmentation score: %.2f\n\n", report.FragmentationScore)
+
+					fmt.Println("Partitions activatable together:")
+					for _, size := range report.Sizes {
+						fmt.Printf("  %2d-GPU: %d (of %d supported)\n", size.NumGPUs, size.Activatable, size.Supported)
+					}
+				}}, nil
+			})
+		},
+	}
+
+	// Verify command
+	verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Check the fabric partition table for inconsistencies",
+		Long: `Check the supported fabric partitions against invariants FabricManager should maintain:
+consistent GPU identity across partitions, no GPU shared by active partitions,
+uniform NVLink line rates, GPU counts matching GPU lists and partition IDs
+within range. Exits with an error when a violation of error severity is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				partitions, err := client.GetSupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get partitions: %w", err)
+				}
+				violations := fabricmanager.VerifyFabric(partitions)
+
+				table := newResultTable(0, "SEVERITY", "CHECK", "PARTITIONS", "MESSAGE")
+				errors := 0
+				for _, violation := range violations {
+					table.addRow(string(violation.Severity), violation.Check, joinIDs(violation.PartitionIDs), violation.Message)
+					if violation.Severity == fabricmanager.SeverityError {
+						errors++
+					}
+				}
+
+				result := &queryResult{data: violations, table: table, text: func() {
+					if len(violations) == 0 {
+						fmt.Println("No violations found")
+						return
+					}
+					for _, violation := range violations {
+						fmt.Println(violation)
+					}
+					fmt.Printf("\n%d violation(s), %d error(s)\n", len(violations), errors)
+				}}
+				if errors > 0 {
+					result.failure = fmt.Errorf("fabric verification failed with %d error(s)", errors)
+				}
+				return result, nil
+			})
 		},
 	}
 
@@ -99,7 +322,7 @@ Management operations include listing, activating, deactivating partitions, etc.
 		RunE: func(cmd *cobra.Command, args []string) error {
 			partitionID, err := strconv.ParseUint(args[0], 10, 32)
 			if err != nil {
-				return fmt.Errorf("invalid partition ID: %v", err)
+				return fmt.Errorf("invalid partition ID: %w", err)
 			}
 
 			client, err := connectToFabricManager()
@@ -109,11 +332,46 @@ Management operations include listing, activating, deactivating partitions, etc.
 			defer client.Disconnect()
 
 			if err := client.ActivatePartition(uint32(partitionID)); err != nil {
-				return fmt.Errorf("failed to activate partition %d: %v", partitionID, err)
+				return fmt.Errorf("failed to activate partition %d: %w", partitionID, err)
 			}
 
-			fmt.Printf("Successfully activated partition %d\n", partitionID)
-			return nil
+			return printResult(actionResult{Action: "activate", PartitionIDs: []uint32{uint32(partitionID)}}, nil, func() {
+				fmt.Printf("Successfully activated partition %d\n", partitionID)
+			})
+		},
+	}
+
+	// Allocate command
+	allocateCmd = &cobra.Command{
+		Use:   "allocate",
+		Short: "Activate a free partition of a given size",
+		Long: `Select and activate an inactive partition with the requested number of GPUs.
+
+Partitions containing GPUs with failed NVLinks are skipped. Among the remaining
+candidates, the one blocking the fewest other partitions is chosen, which keeps
+larger groups of free GPUs available.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if allocateGPUs <= 0 {
+				return fmt.Errorf("--gpus must be a positive number")
+			}
+
+			client, err := connectToFabricManager()
+			if err != nil {
+				return err
+			}
+			defer client.Disconnect()
+
+			allocation, err := client.AllocatePartition(allocateGPUs)
+			if err != nil {
+				return fmt.Errorf("failed to allocate a %d-GPU partition: %w", allocateGPUs, err)
+			}
+
+			return printResult(allocation, nil, func() {
+				fmt.Printf("Successfully activated partition %d\n", allocation.PartitionID)
+				fmt.Printf("  GPU UUIDs: %s\n", strings.Join(allocation.GPUUUIDs, ", "))
+				fmt.Printf("CUDA_VISIBLE_DEVICES=%s\n", allocation.VisibleDevices())
+				fmt.Printf("NVIDIA_VISIBLE_DEVICES=%s\n", allocation.VisibleDevices())
+			})
 		},
 	}
 
@@ -126,7 +384,7 @@ Management operations include listing, activating, deactivating partitions, etc.
 		RunE: func(cmd *cobra.Command, args []string) error {
 			partitionID, err := strconv.ParseUint(args[0], 10, 32)
 			if err != nil {
-				return fmt.Errorf("invalid partition ID: %v", err)
+				return fmt.Errorf("invalid partition ID: %w", err)
 			}
 
 			client, err := connectToFabricManager()
@@ -136,74 +394,155 @@ Management operations include listing, activating, deactivating partitions, etc.
 			defer client.Disconnect()
 
 			if err := client.DeactivatePartition(uint32(partitionID)); err != nil {
-				return fmt.Errorf("failed to deactivate partition %d: %v", partitionID, err)
+				return fmt.Errorf("failed to deactivate partition %d: %w", partitionID, err)
 			}
 
-			fmt.Printf("Successfully deactivated partition %d\n", partitionID)
-			return nil
+			return printResult(actionResult{Action: "deactivate", PartitionIDs: []uint32{uint32(partitionID)}}, nil, func() {
+				fmt.Printf("Successfully deactivated partition %d\n", partitionID)
+			})
 		},
 	}
 
-	// NVLink failed devices command
-	nvlinkFailedCmd = &cobra.Command{
-		Use:   "nvlink-failed",
-		Short: "Query all NVLink failed devices",
-		Long:  "Query all GPUs and NVSwitches with failed NVLinks",
+	// Switch command
+	switchCmd = &cobra.Command{
+		Use:   "switch",
+		Short: "Replace active partitions in a single transaction",
+		Long: `Deactivate the --from partitions and activate the --to partitions as a unit.
+
+The target layout is checked for conflicts first. Deactivations are applied
+before activations, and completed steps are undone if a later step fails.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := parsePartitionIDs(switchFrom)
+			if err != nil {
+				return err
+			}
+			to, err := parsePartitionIDs(switchTo)
+			if err != nil {
+				return err
+			}
+			if len(from) == 0 && len(to) == 0 {
+				return fmt.Errorf("at least one of --from and --to is required")
+			}
+
 			client, err := connectToFabricManager()
 			if err != nil {
 				return err
 			}
 			defer client.Disconnect()
 
-			failedDevices, err := client.GetNvlinkFailedDevices()
+			report, err := client.SwitchPartitions(from, to)
 			if err != nil {
-				return fmt.Errorf("failed to get NVLink failed devices: %v", err)
+				printSteps(report)
+				return &detailedError{err: fmt.Errorf("failed to switch partitions: %w", err), details: report}
 			}
 
-			fmt.Printf("NVLink Failed Devices Report:\n\n")
-			fmt.Printf("GPUs with failed NVLinks: %d\n", failedDevices.NumGPUs)
-			fmt.Printf("NVSwitches with failed NVLinks: %d\n\n", failedDevices.NumSwitches)
+			return printResult(report, stepTable(report), func() {
+				printSteps(report)
+				fmt.Printf("Successfully switched partitions %v to %v\n", from, to)
+			})
+		},
+	}
 
-			if len(failedDevices.GPUInfo) > 0 {
-				fmt.Println("Failed GPUs:")
-				for i, gpu := range failedDevices.GPUInfo {
-					fmt.Printf("  %d. UUID: %s\n", i+1, gpu.UUID)
-					fmt.Printf("     PCI Bus ID: %s\n", gpu.PCIBusID)
-					fmt.Printf("     Failed Ports: %d\n", gpu.NumPorts)
-					if len(gpu.PortNums) > 0 {
-						fmt.Printf("     Port Numbers: %v\n", gpu.PortNums)
-					}
-					fmt.Println()
-				}
+	// Diff command
+	diffCmd = &cobra.Command{
+		Use:   "diff",
+		Short: "Show the changes needed to reach a desired state",
+		Long: `Compare the active partitions with a desired state file and print the plan.
+
+The desired state file lists the partitions that should be active, in YAML or JSON:
+
+  partitions:
+    - id: 1
+    - gpus: [4, 5, 6, 7]`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			desired, err := fabricmanager.LoadDesiredState(desiredStateFile)
+			if err != nil {
+				return fmt.Errorf("failed to load desired state: %w", err)
 			}
 
-			if len(failedDevices.SwitchInfo) > 0 {
-				fmt.Println("Failed NVSwitches:")
-				for i, switch_ := range failedDevices.SwitchInfo {
-					fmt.Printf("  %d. UUID: %s\n", i+1, switch_.UUID)
-					fmt.Printf("     PCI Bus ID: %s\n", switch_.PCIBusID)
-					fmt.Printf("     Failed Ports: %d\n", switch_.NumPorts)
-					if len(switch_.PortNums) > 0 {
-						fmt.Printf("     Port Numbers: %v\n", switch_.PortNums)
-					}
-					fmt.Println()
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				partitions, err := client.GetSupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get partitions: %w", err)
+				}
+
+				plan, err := fabricmanager.NewPlan(desired, partitions, desiredPrune)
+				if err != nil {
+					return nil, fmt.Errorf("failed to plan changes: %w", err)
 				}
+
+				return &queryResult{data: plan, table: planTable(plan), text: func() {
+					fmt.Print(plan)
+				}}, nil
+			})
+		},
+	}
+
+	// Apply command
+	applyCmd = &cobra.Command{
+		Use:   "apply",
+		Short: "Converge the active partitions to a desired state",
+		Long: `Activate and deactivate partitions so that the active partitions match a desired
+state file. Deactivations are applied before activations, and completed steps
+are undone if a step fails. See "fmpm diff --help" for the file format.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			desired, err := fabricmanager.LoadDesiredState(desiredStateFile)
+			if err != nil {
+				return fmt.Errorf("failed to load desired state: %w", err)
 			}
 
-			if len(failedDevices.GPUInfo) == 0 && len(failedDevices.SwitchInfo) == 0 {
-				fmt.Println("No NVLink failures detected")
+			client, err := connectToFabricManager()
+			if err != nil {
+				return err
 			}
+			defer client.Disconnect()
 
-			return nil
+			partitions, err := client.GetSupportedPartitions()
+			if err != nil {
+				return fmt.Errorf("failed to get partitions: %w", err)
+			}
+
+			plan, err := fabricmanager.NewPlan(desired, partitions, desiredPrune)
+			if err != nil {
+				return fmt.Errorf("failed to plan changes: %w", err)
+			}
+
+			result := applyResult{Plan: plan}
+			if !plan.HasChanges() {
+				return printResult(result, planTable(plan), func() {
+					fmt.Print(plan)
+				})
+			}
+
+			if !structuredOutput() {
+				fmt.Print(plan)
+				fmt.Println()
+			}
+			result.Report, err = plan.Apply(client)
+			if err != nil {
+				printSteps(result.Report)
+				return &detailedError{err: fmt.Errorf("failed to apply desired state: %w", err), details: result}
+			}
+
+			return printResult(result, stepTable(result.Report), func() {
+				printSteps(result.Report)
+				fmt.Println("Successfully applied desired state")
+			})
 		},
 	}
 
-	// Unsupported partitions command
-	unsupportedCmd = &cobra.Command{
-		Use:   "unsupported",
-		Short: "List unsupported fabric partitions",
-		Long:  "Query all unsupported fabric partitions",
+	// State command
+	stateCmd = &cobra.Command{
+		Use:   "state",
+		Short: "Save and restore active partitions across FabricManager restarts",
+		Long: `Save the active partitions to a state file and hand them back to a restarted
+FabricManager running in resiliency mode.`,
+	}
+
+	// State save command
+	stateSaveCmd = &cobra.Command{
+		Use:   "save",
+		Short: "Save the active partitions to the state file",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			client, err := connectToFabricManager()
 			if err != nil {
@@ -211,27 +550,139 @@ Management operations include listing, activating, deactivating partitions, etc.
 			}
 			defer client.Disconnect()
 
-			partitions, err := client.GetUnsupportedPartitions()
+			partitions, err := client.GetSupportedPartitions()
 			if err != nil {
-				return fmt.Errorf("failed to get unsupported partitions: %v", err)
+				return fmt.Errorf("failed to get partitions: %w", err)
 			}
 
-			if len(partitions) == 0 {
-				fmt.Println("No unsupported partitions found")
-				return nil
+			state := fabricmanager.NewSavedState(partitions)
+			if err := state.Save(stateFile); err != nil {
+				return fmt.Errorf("failed to save state: %w", err)
+			}
+
+			return printResult(state, nil, func() {
+				fmt.Printf("Saved active partitions %v to %s\n", state.ActivePartitions, stateFile)
+			})
+		},
+	}
+
+	// State restore command
+	stateRestoreCmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the saved active partitions after a FabricManager restart",
+		Long: `Wait for FabricManager to be ready, then set the activated partition list from
+the state file. Restoring is refused if the partition table layout changed
+since the state was saved. When FabricManager restarted in resiliency mode and
+waits for the activated partition list, it does not report its partition table:
+the list is set first and the layout checked afterwards.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := fabricmanager.LoadSavedState(stateFile)
+			if err != nil {
+				return fmt.Errorf("failed to load state: %w", err)
+			}
+			if current, _ := os.Hostname(); state.Hostname != "" && current != state.Hostname {
+				log.Printf("Warning: state was saved on host %s, restoring on %s", state.Hostname, current)
 			}
 
-			fmt.Printf("Found %d unsupported partition(s):\n\n", len(partitions))
-			for _, partition := range partitions {
-				fmt.Printf("Partition ID: %d\n", partition.ID)
-				fmt.Printf("  GPUs: %d\n", partition.NumGPUs)
-				if len(partition.GPUPhysicalIDs) > 0 {
-					fmt.Printf("  GPU Physical IDs: %v\n", partition.GPUPhysicalIDs)
+			ctx, cancel := context.WithTimeout(context.Background(), stateWait)
+			defer cancel()
+
+			// Restoring is retried while FabricManager is starting
+			address := fabricManagerAddress()
+			connected := false
+			err = fabricmanager.WaitForReady(ctx, 2*time.Second, func() error {
+				client, err := connectAddress(address)
+				if err != nil {
+					return err
 				}
-				fmt.Println()
+				defer client.Disconnect()
+				connected = true
+				return fabricmanager.RestoreState(client, state)
+			})
+			if err != nil {
+				if !connected {
+					return fmt.Errorf("failed to connect to FabricManager at %s: %w", describeAddress(address), err)
+				}
+				return fmt.Errorf("failed to restore state: %w", err)
 			}
 
-			return nil
+			return printResult(state, nil, func() {
+				fmt.Printf("Successfully restored activated partitions %v saved at %s\n",
+					state.ActivePartitions, state.SavedAt.Format(time.RFC3339))
+			})
+		},
+	}
+
+	// NVLink failed devices command
+	nvlinkFailedCmd = &cobra.Command{
+		Use:   "nvlink-failed",
+		Short: "Query all NVLink failed devices",
+		Long:  "Query all GPUs and NVSwitches with failed NVLinks, optionally with the partitions they affect",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				failedDevices, err := client.GetNvlinkFailedDevices()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get NVLink failed devices: %w", err)
+				}
+
+				result := nvlinkFailedResult{NvlinkFailedDevices: failedDevices}
+				if nvlinkImpact {
+					partitions, err := client.GetSupportedPartitions()
+					if err != nil {
+						return nil, fmt.Errorf("failed to get partitions: %w", err)
+					}
+					result.Impact = fabricmanager.AnalyzeNvlinkImpact(partitions, failedDevices)
+				}
+
+				return &queryResult{data: result, table: nvlinkFailedTable(result), text: func() {
+					printNvlinkFailedDevices(failedDevices)
+					if result.Impact != nil {
+						printNvlinkImpact(result.Impact)
+					}
+				}}, nil
+			})
+		},
+	}
+
+	// Unsupported partitions command
+	unsupportedCmd = &cobra.Command{
+		Use:   "unsupported",
+		Short: "List unsupported fabric partitions",
+		Long:  "Query all unsupported fabric partitions, optionally explaining why each one is unsupported",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runQuery(cmd, func(host string, client fabricmanager.PartitionManager) (*queryResult, error) {
+				partitions, err := client.GetUnsupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get unsupported partitions: %w", err)
+				}
+
+				if !unsupportedExplain {
+					table := newResultTable(0, "ID", "GPUS", "PHYSICAL IDS")
+					for _, partition := range partitions {
+						table.addRow(strconv.FormatUint(uint64(partition.ID), 10),
+							strconv.FormatUint(uint64(partition.NumGPUs), 10), joinIDs(partition.GPUPhysicalIDs))
+					}
+					return &queryResult{data: partitions, table: table, text: func() { printUnsupportedPartitions(partitions, nil) }}, nil
+				}
+
+				supported, err := client.GetSupportedPartitions()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get partitions: %w", err)
+				}
+				failedDevices, err := client.GetNvlinkFailedDevices()
+				if err != nil {
+					return nil, fmt.Errorf("failed to get NVLink failed devices: %w", err)
+				}
+				explanations := fabricmanager.ExplainUnsupportedPartitions(partitions, supported, failedDevices)
+
+				table := newResultTable(0, "ID", "PHYSICAL IDS", "MISSING", "FAILED", "DEGRADED", "FALLBACKS")
+				for _, explanation := range explanations {
+					table.addRow(strconv.FormatUint(uint64(explanation.ID), 10), joinIDs(explanation.GPUPhysicalIDs),
+						joinIDs(explanation.MissingGPUs), joinIDs(explanation.FailedGPUs),
+						joinIDs(explanation.DegradedGPUs), joinIDs(explanation.Fallbacks))
+				}
+				return &queryResult{data: explanations, table: table, text: func() { printUnsupportedPartitions(partitions, explanations) }}, nil
+			})
 		},
 	}
 
@@ -242,20 +693,9 @@ Management operations include listing, activating, deactivating partitions, etc.
 		Long:  "Set a list of currently activated fabric partitions (comma-separated, no spaces)",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Parse comma-separated partition IDs
-			idStrs := strings.Split(args[0], ",")
-			partitionIDs := make([]uint32, 0, len(idStrs))
-
-			for _, idStr := range idStrs {
-				idStr = strings.TrimSpace(idStr)
-				if idStr == "" {
-					continue
-				}
-				id, err := strconv.ParseUint(idStr, 10, 32)
-				if err != nil {
-					return fmt.Errorf("invalid partition ID '%s': %v", idStr, err)
-				}
-				partitionIDs = append(partitionIDs, uint32(id))
+			partitionIDs, err := parsePartitionIDs(args[0])
+			if err != nil {
+				return err
 			}
 
 			client, err := connectToFabricManager()
@@ -265,11 +705,12 @@ Management operations include listing, activating, deactivating partitions, etc.
 			defer client.Disconnect()
 
 			if err := client.SetActivatedPartitions(partitionIDs); err != nil {
-				return fmt.Errorf("failed to set activated partitions: %v", err)
+				return fmt.Errorf("failed to set activated partitions: %w", err)
 			}
 
-			fmt.Printf("Successfully set activated partitions: %v\n", partitionIDs)
-			return nil
+			return printResult(actionResult{Action: "set-activated", PartitionIDs: partitionIDs}, nil, func() {
+				fmt.Printf("Successfully set activated partitions: %v\n", partitionIDs)
+			})
 		},
 	}
 
@@ -277,8 +718,10 @@ Management operations include listing, activating, deactivating partitions, etc.
 	versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Show version information",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("fmpm version %s\n", fabricmanager.Version)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printResult(versionInfo{Version: fabricmanager.Version}, nil, func() {
+				fmt.Printf("fmpm version %s\n", fabricmanager.Version)
+			})
 		},
 	}
 )
@@ -286,16 +729,66 @@ Management operations include listing, activating, deactivating partitions, etc.
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&hostname, "hostname", "127.0.0.1", "hostname or IP address (TCP socket) of Fabric Manager")
+	rootCmd.PersistentFlags().IntVar(&port, "port", fabricmanager.FM_CMD_PORT_NUMBER, "TCP port of Fabric Manager, unless given in --hostname")
 	rootCmd.PersistentFlags().StringVar(&unixDomainSocket, "unix-domain-socket", "", "UNIX domain socket path for Fabric Manager connection")
 	rootCmd.PersistentFlags().IntVar(&timeoutMs, "timeout", 5000, "connection timeout in milliseconds")
 
+	// List flags
+	listCmd.Flags().BoolVar(&listAvailable, "available", false, "only show inactive partitions that can be activated right now")
+	listCmd.Flags().BoolVar(&listActive, "active", false, "only show active partitions")
+	listCmd.Flags().BoolVar(&listInactive, "inactive", false, "only show inactive partitions")
+	listCmd.Flags().IntVar(&listGPUs, "gpus", 0, "only show partitions with this number of GPUs")
+	listCmd.Flags().StringVar(&listContainsGPU, "contains-gpu", "", "only show partitions including this GPU (UUID, PCI bus ID or physical ID)")
+	listCmd.Flags().BoolVar(&listDegraded, "degraded", false, "only show partitions with degraded NVLinks")
+	listCmd.Flags().StringVar(&listFilter, "filter", "", "only show partitions matching a filter expression")
+	listCmd.Flags().StringVar(&listSortBy, "sort-by", "", "sort by "+strings.Join(fabricmanager.SortKeys, ", ")+" (prefix with - for descending order)")
+	listCmd.Flags().BoolVar(&listIDsOnly, "ids-only", false, "only print partition IDs")
+
+	// Allocate flags
+	allocateCmd.Flags().IntVar(&allocateGPUs, "gpus", 0, "number of GPUs of the partition to activate")
+
+	// Capacity flags
+	capacityCmd.Flags().BoolVar(&capacityJSON, "json", false, "print the report as JSON")
+	_ = capacityCmd.Flags().MarkDeprecated("json", "use -o json instead")
+
+	// NVLink failed devices flags
+	nvlinkFailedCmd.Flags().BoolVar(&nvlinkImpact, "impact", false, "show the partitions and active workloads affected by NVLink failures")
+
+	// Unsupported partitions flags
+	unsupportedCmd.Flags().BoolVar(&unsupportedExplain, "explain", false, "explain why each partition is unsupported and list fallback partitions")
+
+	// Switch flags
+	switchCmd.Flags().StringVar(&switchFrom, "from", "", "partition IDs to deactivate (comma-separated)")
+	switchCmd.Flags().StringVar(&switchTo, "to", "", "partition IDs to activate (comma-separated)")
+
+	// Desired state flags
+	for _, c := range []*cobra.Command{diffCmd, applyCmd} {
+		c.Flags().StringVarP(&desiredStateFile, "filename", "f", "", "desired state file (YAML or JSON)")
+		c.Flags().BoolVar(&desiredPrune, "prune", false, "deactivate active partitions not declared in the desired state")
+		_ = c.MarkFlagRequired("filename")
+	}
+
+	// State flags
+	stateCmd.PersistentFlags().StringVar(&stateFile, "file", "/var/lib/fmpm/state.json", "state file path")
+	stateRestoreCmd.Flags().DurationVar(&stateWait, "wait", 5*time.Minute, "how long to wait for FabricManager to be ready")
+	stateCmd.AddCommand(stateSaveCmd)
+	stateCmd.AddCommand(stateRestoreCmd)
+
 	// Add commands
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(treeCmd)
+	rootCmd.AddCommand(capacityCmd)
+	rootCmd.AddCommand(verifyCmd)
 	rootCmd.AddCommand(activateCmd)
+	rootCmd.AddCommand(allocateCmd)
 	rootCmd.AddCommand(deactivateCmd)
+	rootCmd.AddCommand(switchCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(nvlinkFailedCmd)
 	rootCmd.AddCommand(unsupportedCmd)
 	rootCmd.AddCommand(setActivatedCmd)
+	rootCmd.AddCommand(stateCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	// Add legacy short flags for backward compatibility
@@ -329,8 +822,7 @@ func init() {
 			return setActivatedCmd.RunE(cmd, []string{setActivated})
 		}
 		if version, _ := cmd.Flags().GetBool("version"); version {
-			versionCmd.Run(cmd, args)
-			return nil
+			return versionCmd.RunE(cmd, args)
 		}
 
 		// If no legacy flags, show help
@@ -338,23 +830,372 @@ func init() {
 	}
 }
 
-func connectToFabricManager() (*fabricmanager.Client, error) {
-	var address string
+// versionInfo is the structured output of "fmpm version"
+type versionInfo struct {
+	Version string `json:"version" yaml:"version"`
+}
 
-	if unixDomainSocket != "" {
-		address = unixDomainSocket
+// nvlinkFailedResult is the structured output of "fmpm nvlink-failed"
+type nvlinkFailedResult struct {
+	*fabricmanager.NvlinkFailedDevices `yaml:",inline"`
+	Impact                             *fabricmanager.NvlinkImpactReport `json:"impact,omitempty" yaml:"impact,omitempty"`
+}
+
+// nvlinkFailedTable lists the devices with failed NVLinks, with the
+// partitions using each GPU when the impact was analyzed
+func nvlinkFailedTable(result nvlinkFailedResult) *resultTable {
+	table := newResultTable(0, "TYPE", "UUID", "PCI BUS ID", "FAILED PORTS", "PORT NUMBERS")
+	if result.Impact != nil {
+		table = newResultTable(0, "TYPE", "UUID", "PCI BUS ID", "FAILED PORTS", "PORT NUMBERS", "PARTITIONS", "ACTIVE PARTITIONS")
+	}
+	for i, gpu := range result.GPUInfo {
+		row := []string{"GPU", gpu.UUID, gpu.PCIBusID, strconv.FormatUint(uint64(gpu.NumPorts), 10), joinIDs(gpu.PortNums)}
+		if result.Impact != nil {
+			row = append(row, joinIDs(result.Impact.FailedGPUs[i].Partitions), joinIDs(result.Impact.FailedGPUs[i].ActivePartitions))
+		}
+		table.addRow(row...)
+	}
+	for _, nvswitch := range result.SwitchInfo {
+		row := []string{"NVSwitch", nvswitch.UUID, nvswitch.PCIBusID, strconv.FormatUint(uint64(nvswitch.NumPorts), 10), joinIDs(nvswitch.PortNums)}
+		if result.Impact != nil {
+			row = append(row, "", "")
+		}
+		table.addRow(row...)
+	}
+	return table
+}
+
+// applyResult is the structured output of "fmpm apply"
+type applyResult struct {
+	Plan   *fabricmanager.Plan              `json:"plan" yaml:"plan"`
+	Report *fabricmanager.TransactionReport `json:"report,omitempty" yaml:"report,omitempty"`
+}
+
+// planTable lists the partitions of a plan with the change applied to each
+func planTable(plan *fabricmanager.Plan) *resultTable {
+	table := newResultTable(0, "CHANGE", "ID", "GPUS", "PHYSICAL IDS")
+	for _, group := range []struct {
+		change     string
+		partitions []fabricmanager.Partition
+	}{
+		{"deactivate", plan.Deactivate},
+		{"activate", plan.Activate},
+		{"unchanged", plan.Unchanged},
+		{"unmanaged", plan.Unmanaged},
+	} {
+		for _, partition := range group.partitions {
+			table.addRow(group.change, strconv.FormatUint(uint64(partition.ID), 10),
+				strconv.FormatUint(uint64(partition.NumGPUs), 10), joinIDs(physicalIDs(partition)))
+		}
+	}
+	return table
+}
+
+// stepTable lists the steps of a transaction
+func stepTable(report *fabricmanager.TransactionReport) *resultTable {
+	table := newResultTable(0, "ACTION", "PARTITION", "STATUS", "ERROR")
+	for _, step := range report.Steps {
+		table.addRow(string(step.Action), strconv.FormatUint(uint64(step.PartitionID), 10), string(step.Status), step.Error)
+	}
+	return table
+}
+
+// printSteps prints the steps of a transaction, except for structured output
+func printSteps(report *fabricmanager.TransactionReport) {
+	if structuredOutput() || report == nil {
+		return
+	}
+	for _, step := range report.Steps {
+		fmt.Printf("  %s\n", step)
+	}
+}
+
+// listItem is a partition with its lease, if any
+type listItem struct {
+	fabricmanager.Partition `yaml:",inline"`
+	Lease                   *fabricmanager.Lease `json:"lease,omitempty" yaml:"lease,omitempty"`
+}
+
+// partitionTable is the tabular view of "fmpm list"
+func partitionTable(items []listItem) *resultTable {
+	table := newResultTable(4, "ID", "STATUS", "GPUS", "PHYSICAL IDS", "DEGRADED", "OWNER", "EXPIRES",
+		"BANDWIDTH MB/S", "EFFECTIVE MB/S", "BOTTLENECK GPU", "GPU UUIDS")
+	for _, item := range items {
+		status := "Inactive"
+		if item.IsActive {
+			status = "Active"
+		}
+		owner, expires := "", ""
+		if item.Lease != nil {
+			owner, expires = item.Lease.Owner, item.Lease.ExpiresAt.Format(time.RFC3339)
+		}
+		uuids := make([]string, 0, len(item.GPUs))
+		for _, gpu := range item.GPUs {
+			uuids = append(uuids, gpu.UUID)
+		}
+		bottleneck := ""
+		if gpu, ok := item.BottleneckGPU(); ok {
+			bottleneck = strconv.FormatUint(uint64(gpu.PhysicalID), 10)
+		}
+		table.addRow(
+			strconv.FormatUint(uint64(item.ID), 10),
+			status,
+			strconv.FormatUint(uint64(item.NumGPUs), 10),
+			joinIDs(physicalIDs(item.Partition)),
+			fmt.Sprintf("%.1f%%", item.DegradationPercent()),
+			owner,
+			expires,
+			fmt.Sprintf("%d/%d", item.AvailableBandwidthMBps(), item.MaxBandwidthMBps()),
+			strconv.FormatUint(item.EffectiveBandwidthMBps(), 10),
+			bottleneck,
+			strings.Join(uuids, ","),
+		)
+	}
+	return table
+}
+
+// printPartitions prints the default output of "fmpm list"
+func printPartitions(items []listItem) {
+	if len(items) == 0 {
+		if listAvailable {
+			fmt.Println("No partitions can be activated")
+		} else {
+			fmt.Println("No partitions found")
+		}
+		return
+	}
+	if listAvailable {
+		fmt.Printf("Found %d partition(s) that can be activated:\n\n", len(items))
 	} else {
-		// Check if hostname includes port
-		if !strings.Contains(hostname, ":") {
-			address = fmt.Sprintf("%s:%d", hostname, fabricmanager.FM_CMD_PORT_NUMBER)
+		fmt.Printf("Found %d partition(s):\n\n", len(items))
+	}
+
+	now := time.Now()
+	for _, item := range items {
+		partition := item.Partition
+		status := "Inactive"
+		if partition.IsActive {
+			status = "Active"
+		}
+		fmt.Printf("Partition ID: %d\n", partition.ID)
+		fmt.Printf("  Status: %s\n", status)
+		if item.Lease != nil {
+			fmt.Printf("  Lease: %s, %s\n", item.Lease.Owner, formatLeaseExpiry(*item.Lease, now))
+		}
+		fmt.Printf("  GPUs: %d\n", partition.NumGPUs)
+		fmt.Printf("  NVLink Bandwidth: %d/%d MB/s (%.1f%% degraded)\n",
+			partition.AvailableBandwidthMBps(), partition.MaxBandwidthMBps(), partition.DegradationPercent())
+		if bottleneck, ok := partition.BottleneckGPU(); ok {
+			fmt.Printf("  Effective Bandwidth: %d MB/s (bottleneck GPU %d)\n",
+				partition.EffectiveBandwidthMBps(), bottleneck.PhysicalID)
+		}
+
+		if len(partition.GPUs) > 0 {
+			fmt.Printf("  GPU Details:\n")
+			for _, gpu := range partition.GPUs {
+				fmt.Printf("    Physical ID: %d\n", gpu.PhysicalID)
+				fmt.Printf("    UUID: %s\n", gpu.UUID)
+				fmt.Printf("    PCI Bus ID: %s\n", gpu.PCIBusID)
+				fmt.Printf("    NVLinks Available: %d/%d\n", gpu.NumNvLinksAvailable, gpu.MaxNumNvLinks)
+				fmt.Printf("    Line Rate: %d MB/s\n", gpu.NvlinkLineRateMBps)
+				fmt.Printf("    NVLink Bandwidth: %d/%d MB/s (%.1f%% degraded)\n",
+					gpu.AvailableBandwidthMBps(), gpu.MaxBandwidthMBps(), gpu.DegradationPercent())
+				fmt.Println()
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// printPartitionNode prints a partition and its children using box-drawing
+// characters. prefix is printed before the node, childPrefix before its children.
+func printPartitionNode(node *fabricmanager.PartitionNode, prefix, childPrefix string) {
+	state := string(node.State)
+	if len(node.BlockedBy) > 0 {
+		state = fmt.Sprintf("%s by %s", state, joinIDs(node.BlockedBy))
+	}
+
+	fmt.Printf("%sPartition %d (%d GPUs: %s) [%s]\n", prefix, node.Partition.ID,
+		node.Partition.NumGPUs, joinIDs(physicalIDs(node.Partition)), state)
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			printPartitionNode(child, childPrefix+"└── ", childPrefix+"    ")
 		} else {
-			address = hostname
+			printPartitionNode(child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
+
+// printNvlinkFailedDevices prints the default output of "fmpm nvlink-failed"
+func printNvlinkFailedDevices(failedDevices *fabricmanager.NvlinkFailedDevices) {
+	fmt.Printf("NVLink Failed Devices Report:\n\n")
+	fmt.Printf("GPUs with failed NVLinks: %d\n", failedDevices.NumGPUs)
+	fmt.Printf("NVSwitches with failed NVLinks: %d\n\n", failedDevices.NumSwitches)
+
+	if len(failedDevices.GPUInfo) > 0 {
+		fmt.Println("Failed GPUs:")
+		for i, gpu := range failedDevices.GPUInfo {
+			fmt.Printf("  %d. UUID: %s\n", i+1, gpu.UUID)
+			fmt.Printf("     PCI Bus ID: %s\n", gpu.PCIBusID)
+			fmt.Printf("     Failed Ports: %d\n", gpu.NumPorts)
+			if len(gpu.PortNums) > 0 {
+				fmt.Printf("     Port Numbers: %v\n", gpu.PortNums)
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(failedDevices.SwitchInfo) > 0 {
+		fmt.Println("Failed NVSwitches:")
+		for i, switch_ := range failedDevices.SwitchInfo {
+			fmt.Printf("  %d. UUID: %s\n", i+1, switch_.UUID)
+			fmt.Printf("     PCI Bus ID: %s\n", switch_.PCIBusID)
+			fmt.Printf("     Failed Ports: %d\n", switch_.NumPorts)
+			if len(switch_.PortNums) > 0 {
+				fmt.Printf("     Port Numbers: %v\n", switch_.PortNums)
+			}
+			fmt.Println()
+		}
+	}
+
+	if len(failedDevices.GPUInfo) == 0 && len(failedDevices.SwitchInfo) == 0 {
+		fmt.Println("No NVLink failures detected")
+	}
+}
+
+// printUnsupportedPartitions prints the default output of "fmpm unsupported",
+// with the reasons of each partition if explanations is not nil
+func printUnsupportedPartitions(partitions []fabricmanager.UnsupportedPartition, explanations []fabricmanager.UnsupportedExplanation) {
+	if len(partitions) == 0 {
+		fmt.Println("No unsupported partitions found")
+		return
+	}
+
+	fmt.Printf("Found %d unsupported partition(s):\n\n", len(partitions))
+	for i, partition := range partitions {
+		fmt.Printf("Partition ID: %d\n", partition.ID)
+		fmt.Printf("  GPUs: %d\n", partition.NumGPUs)
+		if len(partition.GPUPhysicalIDs) > 0 {
+			fmt.Printf("  GPU Physical IDs: %v\n", partition.GPUPhysicalIDs)
+		}
+		if explanations != nil {
+			fmt.Printf("  Reasons:\n")
+			for _, reason := range explanations[i].Reasons() {
+				fmt.Printf("    - %s\n", reason)
+			}
+			if len(explanations[i].Fallbacks) > 0 {
+				fmt.Printf("  Fallback partitions: %s\n", joinIDs(explanations[i].Fallbacks))
+			} else {
+				fmt.Printf("  Fallback partitions: none\n")
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// printNvlinkImpact prints which partitions are affected by NVLink failures
+func printNvlinkImpact(report *fabricmanager.NvlinkImpactReport) {
+	fmt.Printf("\nImpact Analysis:\n\n")
+
+	for _, gpu := range report.FailedGPUs {
+		if !gpu.Found {
+			fmt.Printf("Failed GPU %s (%s): not found in the partition table\n", gpu.UUID, gpu.PCIBusID)
+			continue
+		}
+		fmt.Printf("Failed GPU %s (physical ID %d):\n", gpu.UUID, gpu.PhysicalID)
+		fmt.Printf("  Partitions: %s\n", joinIDs(gpu.Partitions))
+		if len(gpu.ActivePartitions) > 0 {
+			fmt.Printf("  Active partitions: %s\n", joinIDs(gpu.ActivePartitions))
+		}
+	}
+
+	if len(report.AffectedPartitions) == 0 {
+		fmt.Println("No partitions affected")
+		return
+	}
+
+	fmt.Println("\nAffected partitions:")
+	for _, partition := range report.AffectedPartitions {
+		status := "Inactive"
+		if partition.IsActive {
+			status = "Active"
+		}
+		fmt.Printf("  Partition %d (%s):", partition.ID, status)
+		if len(partition.FailedGPUs) > 0 {
+			fmt.Printf(" failed GPUs %s", joinIDs(partition.FailedGPUs))
+		}
+		if len(partition.DegradedGPUs) > 0 {
+			fmt.Printf(" degraded GPUs %s", joinIDs(partition.DegradedGPUs))
+		}
+		fmt.Println()
+	}
+
+	if len(report.DegradedWorkloads) > 0 {
+		fmt.Printf("\nDegraded active partitions: %s\n", joinIDs(report.DegradedWorkloads))
+	} else {
+		fmt.Printf("\nNo active partitions are degraded\n")
+	}
+}
+
+// parsePartitionIDs parses a comma-separated list of partition IDs
+func parsePartitionIDs(list string) ([]uint32, error) {
+	idStrs := strings.Split(list, ",")
+	partitionIDs := make([]uint32, 0, len(idStrs))
+
+	for _, idStr := range idStrs {
+		idStr = strings.TrimSpace(idStr)
+		if idStr == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid partition ID '%s': %w", idStr, err)
 		}
+		partitionIDs = append(partitionIDs, uint32(id))
 	}
 
-	client, err := fabricmanager.Connect(address, timeoutMs)
+	return partitionIDs, nil
+}
+
+// physicalIDs returns the physical IDs of the GPUs of a partition
+func physicalIDs(partition fabricmanager.Partition) []uint32 {
+	ids := make([]uint32, 0, len(partition.GPUs))
+	for _, gpu := range partition.GPUs {
+		ids = append(ids, gpu.PhysicalID)
+	}
+	return ids
+}
+
+// joinIDs formats partition IDs as a comma-separated list
+func joinIDs(ids []uint32) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(strs, ",")
+}
+
+// fabricManagerAddress returns the FabricManager address selected by the global flags
+func fabricManagerAddress() string {
+	if unixDomainSocket != "" {
+		return unixDomainSocket
+	}
+
+	// Check if hostname includes port
+	if !strings.Contains(hostname, ":") {
+		return fmt.Sprintf("%s:%d", hostname, port)
+	}
+	return hostname
+}
+
+func connectToFabricManager() (*fabricmanager.Client, error) {
+	printContextHeader()
+	address := fabricManagerAddress()
+
+	client, err := connectAddress(address)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to FabricManager at %s: %v", address, err)
+		return nil, fmt.Errorf("failed to connect to FabricManager at %s: %w", describeAddress(address), err)
 	}
 
 	return client, nil
@@ -362,7 +1203,7 @@ func connectToFabricManager() (*fabricmanager.Client, error) {
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printError(err)
 		os.Exit(1)
 	}
 }
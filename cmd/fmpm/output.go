@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with -o/--output
+const (
+	outputText       = ""
+	outputTable      = "table"
+	outputWide       = "wide"
+	outputJSON       = "json"
+	outputYAML       = "yaml"
+	outputCSV        = "csv"
+	outputGoTemplate = "go-template"
+)
+
+var (
+	// Output flags
+	outputFormat   string
+	outputTemplate string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", "output format: table, wide, json, yaml, csv or go-template=TEMPLATE (default human-readable text)")
+	rootCmd.PersistentFlags().StringVar(&outputTemplate, "template", "", "template for -o go-template, executed on the JSON form of the result")
+}
+
+// validateOutputFormat checks the -o/--output flag, splitting "go-template=TEMPLATE"
+func validateOutputFormat() error {
+	if format, tmpl, ok := strings.Cut(outputFormat, "="); ok && format == outputGoTemplate {
+		outputFormat, outputTemplate = format, tmpl
+	}
+
+	switch outputFormat {
+	case outputText, outputTable, outputWide, outputJSON, outputYAML, outputCSV:
+		return nil
+	case outputGoTemplate:
+		if outputTemplate == "" {
+			return fmt.Errorf("-o go-template requires a template, as go-template=TEMPLATE or with --template")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q: must be one of table, wide, json, yaml, csv or go-template", outputFormat)
+}
+
+// structuredOutput reports whether results are printed as data rather than text
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML || outputFormat == outputGoTemplate
+}
+
+// resultTable is the tabular view of a command result
+type resultTable struct {
+	header []string
+	rows   [][]string
+	// wide is the number of trailing columns only shown with -o wide and csv
+	wide int
+}
+
+// newResultTable creates a table whose last wide columns are only shown with -o wide and csv
+func newResultTable(wide int, header ...string) *resultTable {
+	return &resultTable{header: header, wide: wide}
+}
+
+// addRow appends a row, which must have one value per column
+func (t *resultTable) addRow(values ...string) {
+	t.rows = append(t.rows, values)
+}
+
+// columns returns how many columns are shown in the given format
+func (t *resultTable) columns(format string) int {
+	if format == outputTable {
+		return len(t.header) - t.wide
+	}
+	return len(t.header)
+}
+
+func (t *resultTable) write(w io.Writer, format string) error {
+	n := t.columns(format)
+
+	if format == outputCSV {
+		writer := csv.NewWriter(w)
+		if err := writer.Write(t.header[:n]); err != nil {
+			return err
+		}
+		for _, row := range t.rows {
+			if err := writer.Write(row[:n]); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(t.header[:n], "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(writer, strings.Join(row[:n], "\t"))
+	}
+	return writer.Flush()
+}
+
+// printResult prints the result of a command in the selected output format.
+// data is used by json, yaml and go-template, table by table, wide and csv,
+// and text prints the default human-readable output. Commands without a
+// tabular view pass a nil table and print their text for table and wide.
+func printResult(data any, table *resultTable, text func()) error {
+	switch outputFormat {
+	case outputJSON, outputYAML, outputGoTemplate:
+		return writeData(os.Stdout, data)
+	case outputTable, outputWide, outputCSV:
+		if table != nil {
+			return table.write(os.Stdout, outputFormat)
+		}
+		if outputFormat == outputCSV {
+			return fmt.Errorf("csv output is not supported by this command")
+		}
+	}
+
+	if text != nil {
+		text()
+	}
+	return nil
+}
+
+// writeData encodes data as JSON or YAML, or executes the go-template on it.
+// Templates see the JSON field names, as in the json output.
+func writeData(w io.Writer, data any) error {
+	switch outputFormat {
+	case outputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(data); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case outputGoTemplate:
+		tmpl, err := template.New("output").Option("missingkey=error").Parse(outputTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		var generic any
+		if err := json.Unmarshal(encoded, &generic); err != nil {
+			return err
+		}
+		return tmpl.Execute(w, generic)
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	}
+}
+
+// detailedError attaches a structured result, such as a partial transaction
+// report, to an error
+type detailedError struct {
+	err     error
+	details any
+}
+
+func (e *detailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.err
+}
+
+// reportedError is returned by a command that printed its result before
+// failing, such as verification errors. It is only written to stderr, so that
+// stdout holds a single document in the structured formats.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// errorOutput is the structured form of an error
+type errorOutput struct {
+	Error errorDetail `json:"error" yaml:"error"`
+}
+
+type errorDetail struct {
+	Message string `json:"message" yaml:"message"`
+	// Code is the FabricManager return code, if the error comes from FabricManager
+	Code *int `json:"code,omitempty" yaml:"code,omitempty"`
+	// Kind is connection, resource or partition for FabricManager errors of these classes
+	Kind    string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Details any    `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// newErrorDetail returns the structured form of an error
+func newErrorDetail(err error) errorDetail {
+	detail := errorDetail{Message: err.Error()}
+	var fmErr *fabricmanager.FMError
+	if errors.As(err, &fmErr) {
+		detail.Code = &fmErr.Code
+		switch {
+		case fabricmanager.IsConnectionError(fmErr):
+			detail.Kind = "connection"
+		case fabricmanager.IsResourceError(fmErr):
+			detail.Kind = "resource"
+		case fabricmanager.IsPartitionError(fmErr):
+			detail.Kind = "partition"
+		}
+	}
+	var detailed *detailedError
+	if errors.As(err, &detailed) {
+		detail.Details = detailed.details
+	}
+	return detail
+}
+
+// printError prints an error on stderr, or as a structured object on stdout
+// in the json, yaml and go-template formats (go-template errors are JSON).
+// Errors following a printed result only go to stderr.
+func printError(err error) {
+	var reported *reportedError
+	if !structuredOutput() || errors.As(err, &reported) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	detail := newErrorDetail(err)
+	if outputFormat == outputGoTemplate {
+		outputFormat = outputJSON
+	}
+	if writeErr := writeData(os.Stdout, errorOutput{Error: detail}); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// actionResult is the structured result of commands changing partitions
+type actionResult struct {
+	Action       string   `json:"action" yaml:"action"`
+	PartitionIDs []uint32 `json:"partitionIds" yaml:"partitionIds"`
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fleetFindGPUs int
+
+	// Fleet command group
+	fleetCmd = &cobra.Command{
+		Use:   "fleet",
+		Short: "Query several hosts as a whole",
+	}
+
+	fleetFindCmd = &cobra.Command{
+		Use:   "find",
+		Short: "Rank hosts that can activate a partition of a given size",
+		Long: `Find the hosts given with --hosts or --inventory that can activate a partition
+with the requested number of GPUs right now, and rank them by fit.
+
+Each host is evaluated with the partition allocate would select, skipping GPUs
+with failed NVLinks. Hosts leaving the least fragmentation once the partition
+is active come first, then hosts whose partition has the healthiest NVLinks,
+then the hosts with the fewest free GPUs, keeping emptier hosts for larger
+requests. Exits with an error when no host can activate such a partition.`,
+		Annotations: map[string]string{fleetAnnotation: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fleetFindGPUs <= 0 {
+				return fmt.Errorf("--gpus must be a positive number")
+			}
+			hosts, err := fleetHosts()
+			if err != nil {
+				return err
+			}
+			if len(hosts) == 0 {
+				return fmt.Errorf("fleet find requires --hosts or --inventory")
+			}
+			cmd.SilenceUsage = true
+
+			fits, results := newCLIFleet(hosts).FindHosts(fleetFindGPUs)
+			result := fleetFindResult{NumGPUs: fleetFindGPUs, Hosts: fits, Unavailable: map[string]errorDetail{}}
+			for _, hostResult := range results {
+				if hostResult.Err != nil {
+					result.Unavailable[hostResult.Host] = newErrorDetail(hostResult.Err)
+				}
+			}
+
+			if err := printResult(result, fleetFindTable(result, results), func() { printFleetFind(result) }); err != nil {
+				return err
+			}
+			if len(fits) == 0 {
+				return &reportedError{err: fmt.Errorf("no host can activate a %d-GPU partition", fleetFindGPUs)}
+			}
+			return nil
+		},
+	}
+)
+
+// fleetFindResult is the ranked hosts able to activate a partition and the
+// reason every other host cannot
+type fleetFindResult struct {
+	NumGPUs     int                     `json:"numGPUs" yaml:"numGPUs"`
+	Hosts       []fabricmanager.HostFit `json:"hosts" yaml:"hosts"`
+	Unavailable map[string]errorDetail  `json:"unavailable,omitempty" yaml:"unavailable,omitempty"`
+}
+
+// fleetFindTable is the tabular view of a fleet search, ranked hosts first
+func fleetFindTable(result fleetFindResult, results []fabricmanager.HostResult) *resultTable {
+	table := newResultTable(1, "RANK", "HOST", "PARTITION", "FREE GPUS", "ACTIVATABLE", "FRAGMENTATION", "DEGRADED", "ERROR", "GPU UUIDS")
+	for i, fit := range result.Hosts {
+		table.addRow(strconv.Itoa(i+1), fit.Host, strconv.FormatUint(uint64(fit.PartitionID), 10),
+			strconv.Itoa(fit.FreeGPUs), strconv.Itoa(fit.Activatable),
+			fmt.Sprintf("%.2f", fit.FragmentationAfter), fmt.Sprintf("%.1f%%", fit.DegradationPercent),
+			"", strings.Join(fit.GPUUUIDs, ","))
+	}
+	for _, hostResult := range results {
+		if hostResult.Err != nil {
+			table.addRow("", hostResult.Host, "", "", "", "", "", result.Unavailable[hostResult.Host].Message, "")
+		}
+	}
+	return table
+}
+
+func printFleetFind(result fleetFindResult) {
+	if len(result.Hosts) == 0 {
+		fmt.Printf("No host can activate a %d-GPU partition\n", result.NumGPUs)
+	} else {
+		fmt.Printf("%d host(s) can activate a %d-GPU partition, best fit first:\n", len(result.Hosts), result.NumGPUs)
+		for i, fit := range result.Hosts {
+			fmt.Printf("  %d. %s: partition %d, %d free GPU(s), %d activatable, fragmentation %.2f after, %.1f%% degraded\n",
+				i+1, fit.Host, fit.PartitionID, fit.FreeGPUs, fit.Activatable, fit.FragmentationAfter, fit.DegradationPercent)
+		}
+	}
+
+	if len(result.Unavailable) > 0 {
+		fmt.Println("\nCannot activate:")
+		hosts := make([]string, 0, len(result.Unavailable))
+		for host := range result.Unavailable {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			fmt.Printf("  %s: %s\n", host, result.Unavailable[host].Message)
+		}
+	}
+}
+
+func init() {
+	fleetFindCmd.Flags().IntVar(&fleetFindGPUs, "gpus", 0, "number of GPUs of the partition to place")
+
+	fleetCmd.AddCommand(fleetFindCmd)
+	rootCmd.AddCommand(fleetCmd)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Serve flags
+	serveListen      string
+	serveAuthFile    string
+	serveTLSCert     string
+	serveTLSKey      string
+	serveTLSClientCA string
+
+	// Serve command
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the partition operations over a REST API",
+		Long: `Serve the partition operations of FabricManager over a versioned REST API, for
+clients that cannot link libnvfm. The OpenAPI document is served at
+/v1/openapi.yaml and /v1/openapi.json.
+
+Requests are authenticated with a bearer token or, with --tls-client-ca, a TLS
+client certificate identified by its subject common name. The credentials file
+lists the tokens and client certificates with their role:
+
+  tokens:
+    - name: portal
+      token: <secret>
+      role: read-only
+    - name: scheduler
+      token: <secret>
+      role: admin
+  clients:
+    - commonName: ops.example.com
+      role: admin
+
+Client certificate entries require --tls-client-ca. The read-only role may list
+partitions, the admin role may also activate and deactivate them. Partition
+changes take the host lock and are recorded in the audit log with the name of
+the API client, such as "token scheduler". SIGINT and SIGTERM stop the server.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (serveTLSCert == "") != (serveTLSKey == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be given together")
+			}
+			if serveTLSClientCA != "" && serveTLSCert == "" {
+				return fmt.Errorf("--tls-client-ca requires --tls-cert and --tls-key")
+			}
+			cmd.SilenceUsage = true
+
+			auth, err := fabricmanager.LoadAPIAuth(serveAuthFile)
+			if err != nil {
+				return fmt.Errorf("failed to load API credentials: %w", err)
+			}
+			// Client certificates are only verified, and so only identify
+			// anyone, with the client CA
+			if len(auth.Clients) > 0 && serveTLSClientCA == "" {
+				return fmt.Errorf("client certificates of %s require --tls-client-ca", serveAuthFile)
+			}
+
+			address := fabricManagerAddress()
+			api := &fabricmanager.APIServer{
+				Auth: auth,
+				Connect: func(actor string) (fabricmanager.HostConnection, error) {
+					client, err := connectAddress(address)
+					if err != nil {
+						return nil, err
+					}
+					client.SetAuditActor(actor)
+					return client, nil
+				},
+				Lock: withHostLock,
+				OnRequest: func(r *http.Request, client string, status int) {
+					if client == "" {
+						client = "-"
+					}
+					log.Printf("%s %s %s %s %d", r.RemoteAddr, client, r.Method, r.URL.Path, status)
+				},
+			}
+
+			server := &http.Server{
+				Handler:           api.Handler(),
+				ReadHeaderTimeout: 5 * time.Second,
+			}
+			if serveTLSClientCA != "" {
+				pool, err := loadCertPool(serveTLSClientCA)
+				if err != nil {
+					return err
+				}
+				server.TLSConfig = &tls.Config{
+					ClientCAs:  pool,
+					ClientAuth: tls.VerifyClientCertIfGiven,
+					MinVersion: tls.VersionTLS12,
+				}
+			}
+			return runServer(server, describeAddress(address))
+		},
+	}
+)
+
+// loadCertPool loads the PEM certificates of path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in client CA %s", path)
+	}
+	return pool, nil
+}
+
+// runServer serves the API until SIGINT or SIGTERM
+func runServer(server *http.Server, address string) error {
+	listener, err := net.Listen("tcp", serveListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", serveListen, err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if serveTLSCert != "" {
+			errs <- server.ServeTLS(listener, serveTLSCert, serveTLSKey)
+		} else {
+			errs <- server.Serve(listener)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	scheme := "https"
+	if serveTLSCert == "" {
+		scheme = "http"
+		log.Printf("Warning: serving without TLS, bearer tokens are sent in clear text")
+	}
+	log.Printf("API server started: %s://%s/%s, FabricManager %s", scheme, listener.Addr(), fabricmanager.APIVersion, address)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("failed to serve API: %w", err)
+	case sig := <-signals:
+		log.Printf("Received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to stop API server: %w", err)
+		}
+		return nil
+	}
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8080", "address to listen on")
+	serveCmd.Flags().StringVar(&serveAuthFile, "auth-file", "", "file with the API tokens and client certificates and their roles (required)")
+	serveCmd.Flags().StringVar(&serveTLSCert, "tls-cert", "", "TLS certificate to serve HTTPS")
+	serveCmd.Flags().StringVar(&serveTLSKey, "tls-key", "", "TLS private key of --tls-cert")
+	serveCmd.Flags().StringVar(&serveTLSClientCA, "tls-client-ca", "", "CA certificates verifying TLS client certificates, enabling mutual TLS")
+	_ = serveCmd.MarkFlagRequired("auth-file")
+	rootCmd.AddCommand(serveCmd)
+}
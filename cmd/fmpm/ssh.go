@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-fabricmanager"
+)
+
+var (
+	// SSH transport flags
+	sshHost       string
+	sshJump       string
+	sshIdentity   string
+	sshKnownHosts string
+)
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sshConfig returns the SSH settings given with the --ssh flags
+func sshConfig() fabricmanager.SSHConfig {
+	return fabricmanager.SSHConfig{
+		Host:            sshHost,
+		JumpHosts:       splitList(sshJump),
+		IdentityFiles:   splitList(sshIdentity),
+		KnownHostsFiles: splitList(sshKnownHosts),
+		Timeout:         time.Duration(timeoutMs) * time.Millisecond,
+	}
+}
+
+// connectAddress connects to FabricManager at address, through the SSH host
+// given with --ssh if any
+func connectAddress(address string) (*fabricmanager.Client, error) {
+	if sshHost == "" {
+		return fabricmanager.Connect(address, timeoutMs)
+	}
+	return fabricmanager.ConnectSSH(sshConfig(), address, timeoutMs)
+}
+
+// describeAddress returns address with the SSH host it is reached through, if any
+func describeAddress(address string) string {
+	if sshHost == "" {
+		return address
+	}
+	return fmt.Sprintf("%s via ssh %s", address, sshHost)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&sshHost, "ssh", "", "reach FabricManager through this SSH host, as [user@]host[:port]")
+	rootCmd.PersistentFlags().StringVar(&sshJump, "ssh-jump", "", "comma-separated SSH jump hosts to go through to reach --ssh, as [user@]host[:port]")
+	rootCmd.PersistentFlags().StringVar(&sshIdentity, "ssh-identity", "", "comma-separated private keys for SSH, tried after the SSH agent (default the keys of ~/.ssh)")
+	rootCmd.PersistentFlags().StringVar(&sshKnownHosts, "ssh-known-hosts", "", "comma-separated known hosts files verifying SSH host keys (default ~/.ssh/known_hosts)")
+}
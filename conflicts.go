@@ -0,0 +1,175 @@
+package fabricmanager
+
+import (
+	"sort"
+)
+
+// PartitionState describes whether a partition is in use or can be activated
+type PartitionState string
+
+const (
+	// PartitionStateActive means the partition is currently active
+	PartitionStateActive PartitionState = "active"
+	// PartitionStateAvailable means the partition is inactive and can be activated
+	PartitionStateAvailable PartitionState = "available"
+	// PartitionStateBlocked means the partition shares GPUs with an active partition
+	PartitionStateBlocked PartitionState = "blocked"
+)
+
+// ConflictGraph records which fabric partitions share GPUs with each other.
+// Two partitions conflict when they have at least one GPU physical ID in
+// common; at most one partition of a conflicting pair can be active.
+type ConflictGraph struct {
+	ids        []uint32
+	partitions map[uint32]Partition
+	edges      map[uint32]map[uint32]struct{}
+}
+
+// NewConflictGraph builds the conflict graph of a supported partition table
+func NewConflictGraph(partitions []Partition) *ConflictGraph {
+	g := &ConflictGraph{
+		ids:        make([]uint32, 0, len(partitions)),
+		partitions: make(map[uint32]Partition, len(partitions)),
+		edges:      make(map[uint32]map[uint32]struct{}, len(partitions)),
+	}
+
+	// Index partitions by the GPUs they use
+	users := make(map[uint32][]uint32)
+	for _, partition := range partitions {
+		if _, ok := g.partitions[partition.ID]; ok {
+			continue
+		}
+		g.ids = append(g.ids, partition.ID)
+		g.partitions[partition.ID] = partition
+		g.edges[partition.ID] = make(map[uint32]struct{})
+		for _, gpu := range partition.GPUs {
+			users[gpu.PhysicalID] = append(users[gpu.PhysicalID], partition.ID)
+		}
+	}
+	sort.Slice(g.ids, func(i, j int) bool { return g.ids[i] < g.ids[j] })
+
+	// Every pair of partitions using the same GPU conflicts
+	for _, ids := range users {
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				if ids[i] == ids[j] {
+					continue
+				}
+				g.edges[ids[i]][ids[j]] = struct{}{}
+				g.edges[ids[j]][ids[i]] = struct{}{}
+			}
+		}
+	}
+
+	return g
+}
+
+// Partition returns the partition with the given ID
+func (g *ConflictGraph) Partition(id uint32) (Partition, bool) {
+	partition, ok := g.partitions[id]
+	return partition, ok
+}
+
+// Partitions returns all partitions in the graph, sorted by ID
+func (g *ConflictGraph) Partitions() []Partition {
+	partitions := make([]Partition, 0, len(g.ids))
+	for _, id := range g.ids {
+		partitions = append(partitions, g.partitions[id])
+	}
+	return partitions
+}
+
+// Conflicts returns the IDs of the partitions sharing GPUs with the given one, sorted
+func (g *ConflictGraph) Conflicts(id uint32) []uint32 {
+	conflicts := make([]uint32, 0, len(g.edges[id]))
+	for other := range g.edges[id] {
+		conflicts = append(conflicts, other)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i] < conflicts[j] })
+	return conflicts
+}
+
+// ConflictsWith reports whether two partitions share at least one GPU
+func (g *ConflictGraph) ConflictsWith(a, b uint32) bool {
+	_, ok := g.edges[a][b]
+	return ok
+}
+
+// ActiveIDs returns the IDs of the partitions reported as active, sorted
+func (g *ConflictGraph) ActiveIDs() []uint32 {
+	var active []uint32
+	for _, id := range g.ids {
+		if g.partitions[id].IsActive {
+			active = append(active, id)
+		}
+	}
+	return active
+}
+
+// CanActivate reports whether a partition can be activated while the given
+// partitions are active. Active partitions themselves cannot be activated again.
+func (g *ConflictGraph) CanActivate(id uint32, active []uint32) bool {
+	if _, ok := g.partitions[id]; !ok {
+		return false
+	}
+	for _, activeID := range active {
+		if activeID == id || g.ConflictsWith(id, activeID) {
+			return false
+		}
+	}
+	return true
+}
+
+// BlockedBy returns the active partitions preventing the given partition from
+// being activated, sorted
+func (g *ConflictGraph) BlockedBy(id uint32, active []uint32) []uint32 {
+	var blockers []uint32
+	for _, activeID := range active {
+		if activeID != id && g.ConflictsWith(id, activeID) {
+			blockers = append(blockers, activeID)
+		}
+	}
+	sort.Slice(blockers, func(i, j int) bool { return blockers[i] < blockers[j] })
+	return blockers
+}
+
+// State returns the state of a partition while the given partitions are active
+func (g *ConflictGraph) State(id uint32, active []uint32) PartitionState {
+	for _, activeID := range active {
+		if activeID == id {
+			return PartitionStateActive
+		}
+	}
+	if len(g.BlockedBy(id, active)) > 0 {
+		return PartitionStateBlocked
+	}
+	return PartitionStateAvailable
+}
+
+// Available returns the inactive partitions that can still be activated while
+// the given partitions are active, sorted by ID
+func (g *ConflictGraph) Available(active []uint32) []Partition {
+	var available []Partition
+	for _, id := range g.ids {
+		if g.CanActivate(id, active) {
+			available = append(available, g.partitions[id])
+		}
+	}
+	return available
+}
+
+// AvailablePartitions returns the partitions that can be activated given the
+// active state reported in the partition table
+func AvailablePartitions(partitions []Partition) []Partition {
+	g := NewConflictGraph(partitions)
+	return g.Available(g.ActiveIDs())
+}
+
+// GetAvailablePartitions gets the list of fabric partitions that can be activated right now
+func (c *Client) GetAvailablePartitions() ([]Partition, error) {
+	partitions, err := c.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	return AvailablePartitions(partitions), nil
+}
@@ -0,0 +1,112 @@
+package fabricmanager
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testPartition builds a partition using the given GPU physical IDs
+func testPartition(id uint32, active bool, physicalIDs ...uint32) Partition {
+	partition := Partition{
+		ID:       id,
+		IsActive: active,
+		NumGPUs:  uint32(len(physicalIDs)),
+		GPUs:     make([]PartitionGPUInfo, len(physicalIDs)),
+	}
+	for i, physicalID := range physicalIDs {
+		partition.GPUs[i] = PartitionGPUInfo{
+			PhysicalID:          physicalID,
+			UUID:                fmt.Sprintf("GPU-%08d", physicalID),
+			PCIBusID:            fmt.Sprintf("00000000:%02X:00.0", physicalID+0x10),
+			NumNvLinksAvailable: 18,
+			MaxNumNvLinks:       18,
+			NvlinkLineRateMBps:  25781,
+		}
+	}
+	return partition
+}
+
+// testPartitionTable returns the partition table of an 8-GPU HGX baseboard:
+// one 8-GPU partition, two 4-GPU, four 2-GPU and eight 1-GPU partitions.
+// The partitions whose IDs are listed in active are marked active.
+func testPartitionTable(active ...uint32) []Partition {
+	partitions := []Partition{
+		testPartition(0, false, 0, 1, 2, 3, 4, 5, 6, 7),
+		testPartition(1, false, 0, 1, 2, 3),
+		testPartition(2, false, 4, 5, 6, 7),
+		testPartition(3, false, 0, 1),
+		testPartition(4, false, 2, 3),
+		testPartition(5, false, 4, 5),
+		testPartition(6, false, 6, 7),
+	}
+	for i := uint32(0); i < 8; i++ {
+		partitions = append(partitions, testPartition(7+i, false, i))
+	}
+	for i := range partitions {
+		for _, id := range active {
+			if partitions[i].ID == id {
+				partitions[i].IsActive = true
+			}
+		}
+	}
+	return partitions
+}
+
+func TestConflictGraph(t *testing.T) {
+	g := NewConflictGraph(testPartitionTable())
+
+	// The 8-GPU partition conflicts with every other partition
+	if conflicts := g.Conflicts(0); len(conflicts) != 14 {
+		t.Errorf("Expected partition 0 to conflict with 14 partitions, got %v", conflicts)
+	}
+
+	expected := []uint32{0, 1, 9, 10}
+	if conflicts := g.Conflicts(4); !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected partition 4 to conflict with %v, got %v", expected, conflicts)
+	}
+
+	if g.ConflictsWith(1, 2) {
+		t.Error("Expected disjoint 4-GPU partitions not to conflict")
+	}
+	if !g.ConflictsWith(2, 14) {
+		t.Error("Expected partition 2 to conflict with the 1-GPU partition of GPU 7")
+	}
+}
+
+func TestAvailablePartitions(t *testing.T) {
+	// Nothing active: everything can be activated
+	if available := AvailablePartitions(testPartitionTable()); len(available) != 15 {
+		t.Errorf("Expected 15 available partitions, got %v", partitionIDList(available))
+	}
+
+	// One 4-GPU partition active: only the other half remains
+	expected := []uint32{2, 5, 6, 11, 12, 13, 14}
+	if available := AvailablePartitions(testPartitionTable(1)); !reflect.DeepEqual(partitionIDList(available), expected) {
+		t.Errorf("Expected available partitions %v, got %v", expected, partitionIDList(available))
+	}
+
+	// The 8-GPU partition active: nothing else can be activated
+	if available := AvailablePartitions(testPartitionTable(0)); len(available) != 0 {
+		t.Errorf("Expected no available partitions, got %v", partitionIDList(available))
+	}
+}
+
+func TestCanActivate(t *testing.T) {
+	g := NewConflictGraph(testPartitionTable())
+
+	if !g.CanActivate(2, []uint32{3, 4}) {
+		t.Error("Expected partition 2 to be activatable next to partitions 3 and 4")
+	}
+	if g.CanActivate(3, []uint32{3}) {
+		t.Error("Expected an active partition not to be activatable again")
+	}
+	if g.CanActivate(99, nil) {
+		t.Error("Expected an unknown partition not to be activatable")
+	}
+
+	expected := []uint32{3, 4}
+	if blockers := g.BlockedBy(1, []uint32{4, 3, 6}); !reflect.DeepEqual(blockers, expected) {
+		t.Errorf("Expected partition 1 to be blocked by %v, got %v", expected, blockers)
+	}
+}
@@ -0,0 +1,244 @@
+package fabricmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DesiredPartition identifies a partition that should be active, either by
+// ID or by the exact set of GPU physical IDs it contains
+type DesiredPartition struct {
+	ID   *uint32  `json:"id,omitempty" yaml:"id,omitempty"`
+	GPUs []uint32 `json:"gpus,omitempty" yaml:"gpus,omitempty"`
+}
+
+func (p DesiredPartition) String() string {
+	if p.ID != nil {
+		return fmt.Sprintf("partition %d", *p.ID)
+	}
+	return fmt.Sprintf("partition with GPUs %s", formatIDs(p.GPUs))
+}
+
+// DesiredState lists the partitions that should be active on a node. It is
+// read from YAML or JSON:
+//
+//	partitions:
+//	  - id: 1
+//	  - gpus: [4, 5, 6, 7]
+type DesiredState struct {
+	Partitions []DesiredPartition `json:"partitions" yaml:"partitions"`
+}
+
+// ParseDesiredState parses a desired state document in YAML or JSON
+func ParseDesiredState(data []byte) (*DesiredState, error) {
+	var state DesiredState
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&state); err != nil {
+		return nil, fmt.Errorf("invalid desired state: %v", err)
+	}
+
+	for i, partition := range state.Partitions {
+		if (partition.ID == nil) == (len(partition.GPUs) == 0) {
+			return nil, fmt.Errorf("invalid desired state: entry %d must have exactly one of id and gpus", i+1)
+		}
+	}
+
+	return &state, nil
+}
+
+// LoadDesiredState reads a desired state file in YAML or JSON
+func LoadDesiredState(path string) (*DesiredState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDesiredState(data)
+}
+
+// Resolve returns the IDs of the desired partitions in a partition table, sorted
+func (d *DesiredState) Resolve(partitions []Partition) ([]uint32, error) {
+	g := NewConflictGraph(partitions)
+
+	// Index partitions by their GPU set
+	bySet := make(map[string]uint32)
+	for _, partition := range g.Partitions() {
+		bySet[formatIDs(sortedIDs(gpuSet(partition)))] = partition.ID
+	}
+
+	resolved := make(map[uint32]struct{})
+	for _, desired := range d.Partitions {
+		var id uint32
+		if desired.ID != nil {
+			id = *desired.ID
+			if _, ok := g.Partition(id); !ok {
+				return nil, fmt.Errorf("%s does not exist", desired)
+			}
+		} else {
+			set := make(map[uint32]struct{})
+			for _, physicalID := range desired.GPUs {
+				set[physicalID] = struct{}{}
+			}
+			var ok bool
+			if id, ok = bySet[formatIDs(sortedIDs(set))]; !ok {
+				return nil, fmt.Errorf("no supported %s", desired)
+			}
+		}
+		if _, ok := resolved[id]; ok {
+			return nil, fmt.Errorf("partition %d is declared more than once", id)
+		}
+		resolved[id] = struct{}{}
+	}
+
+	return sortedIDs(resolved), nil
+}
+
+// Plan is the set of changes converging a node to a desired state
+type Plan struct {
+	Activate   []Partition `json:"activate" yaml:"activate"`
+	Deactivate []Partition `json:"deactivate" yaml:"deactivate"`
+	Unchanged  []Partition `json:"unchanged" yaml:"unchanged"`
+	// Unmanaged are active partitions not declared in the desired state and left active
+	Unmanaged []Partition `json:"unmanaged" yaml:"unmanaged"`
+}
+
+// NewPlan computes the changes needed to reach the desired state from the
+// active state reported in the partition table. Active partitions not declared
+// in the desired state are deactivated if prune is set and left alone
+// otherwise; leaving one that conflicts with a desired partition is an error.
+func NewPlan(desired *DesiredState, partitions []Partition, prune bool) (*Plan, error) {
+	ids, err := desired.Resolve(partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	g := NewConflictGraph(partitions)
+	wanted := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	plan := &Plan{
+		Activate:   []Partition{},
+		Deactivate: []Partition{},
+		Unchanged:  []Partition{},
+		Unmanaged:  []Partition{},
+	}
+	for _, partition := range g.Partitions() {
+		switch {
+		case wanted[partition.ID] && partition.IsActive:
+			plan.Unchanged = append(plan.Unchanged, partition)
+		case wanted[partition.ID]:
+			plan.Activate = append(plan.Activate, partition)
+		case partition.IsActive && prune:
+			plan.Deactivate = append(plan.Deactivate, partition)
+		case partition.IsActive:
+			plan.Unmanaged = append(plan.Unmanaged, partition)
+		}
+	}
+
+	for _, unmanaged := range plan.Unmanaged {
+		for _, partition := range plan.Activate {
+			if g.ConflictsWith(unmanaged.ID, partition.ID) {
+				return nil, fmt.Errorf("partition %d is active but not declared and shares GPUs with desired partition %d; declare it or use prune",
+					unmanaged.ID, partition.ID)
+			}
+		}
+	}
+
+	// Overlapping desired partitions are reported by the transaction
+	if err := plan.Transaction().Validate(partitions); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// HasChanges reports whether applying the plan changes anything
+func (p *Plan) HasChanges() bool {
+	return len(p.Activate) > 0 || len(p.Deactivate) > 0
+}
+
+// Transaction returns the transaction executing the plan
+func (p *Plan) Transaction() *Transaction {
+	t := NewTransaction()
+	t.Deactivate(partitionIDList(p.Deactivate)...)
+	t.Activate(partitionIDList(p.Activate)...)
+	return t
+}
+
+// Apply executes the plan as a transaction, deactivating before activating
+// and rolling back on failure
+func (p *Plan) Apply(pm PartitionManager) (*TransactionReport, error) {
+	return p.Transaction().Apply(pm)
+}
+
+// String renders the plan in the style of infrastructure-as-code tools
+func (p *Plan) String() string {
+	var b strings.Builder
+
+	type line struct {
+		id   uint32
+		text string
+	}
+	var lines []line
+	describe := func(partition Partition) string {
+		physicalIDs := make([]uint32, 0, len(partition.GPUs))
+		for _, gpu := range partition.GPUs {
+			physicalIDs = append(physicalIDs, gpu.PhysicalID)
+		}
+		return fmt.Sprintf("partition %d (%d GPUs: %s)", partition.ID, len(partition.GPUs), formatIDs(physicalIDs))
+	}
+	for _, partition := range p.Deactivate {
+		lines = append(lines, line{partition.ID, fmt.Sprintf("  - %s will be deactivated\n", describe(partition))})
+	}
+	for _, partition := range p.Activate {
+		lines = append(lines, line{partition.ID, fmt.Sprintf("  + %s will be activated\n", describe(partition))})
+	}
+	for _, partition := range p.Unmanaged {
+		lines = append(lines, line{partition.ID, fmt.Sprintf("  ! %s is active but not declared\n", describe(partition))})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].id < lines[j].id })
+	for _, l := range lines {
+		b.WriteString(l.text)
+	}
+
+	if !p.HasChanges() {
+		b.WriteString("No changes. The active partitions match the desired state.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nPlan: %d to activate, %d to deactivate, %d unchanged.\n", len(p.Activate), len(p.Deactivate), len(p.Unchanged))
+	return b.String()
+}
+
+// partitionIDList returns the IDs of partitions, in order
+func partitionIDList(partitions []Partition) []uint32 {
+	ids := make([]uint32, 0, len(partitions))
+	for _, partition := range partitions {
+		ids = append(ids, partition.ID)
+	}
+	return ids
+}
+
+// Reconcile computes the plan reaching the desired state and applies it.
+// The report is nil when there is nothing to change.
+func Reconcile(pm PartitionManager, desired *DesiredState, prune bool) (*Plan, *TransactionReport, error) {
+	partitions, err := pm.GetSupportedPartitions()
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := NewPlan(desired, partitions, prune)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !plan.HasChanges() {
+		return plan, nil, nil
+	}
+	report, err := plan.Apply(pm)
+	return plan, report, err
+}
@@ -0,0 +1,95 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDesiredState(t *testing.T) {
+	yamlState, err := ParseDesiredState([]byte("partitions:\n  - id: 1\n  - gpus: [7, 6, 5, 4]\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	jsonState, err := ParseDesiredState([]byte(`{"partitions": [{"id": 1}, {"gpus": [7, 6, 5, 4]}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(yamlState, jsonState) {
+		t.Errorf("Expected YAML and JSON documents to match, got %+v and %+v", yamlState, jsonState)
+	}
+
+	ids, err := yamlState.Resolve(testPartitionTable())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []uint32{1, 2}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected resolved partitions %v, got %v", expected, ids)
+	}
+
+	invalid := []string{
+		"partitions:\n  - id: 1\n    gpus: [0]\n",
+		"partitions:\n  - {}\n",
+		"partitions:\n  - name: foo\n",
+	}
+	for _, doc := range invalid {
+		if _, err := ParseDesiredState([]byte(doc)); err == nil {
+			t.Errorf("Expected %q to be rejected", doc)
+		}
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	desired, _ := ParseDesiredState([]byte("partitions:\n  - id: 1\n  - id: 5\n"))
+
+	// Partition 0 is active and not declared: an error unless pruned
+	if _, err := NewPlan(desired, testPartitionTable(0), false); err == nil {
+		t.Error("Expected a conflicting undeclared partition to be reported")
+	}
+
+	plan, err := NewPlan(desired, testPartitionTable(0), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(partitionIDList(plan.Deactivate), []uint32{0}) ||
+		!reflect.DeepEqual(partitionIDList(plan.Activate), []uint32{1, 5}) {
+		t.Errorf("Expected to deactivate [0] and activate [1 5], got %v and %v",
+			partitionIDList(plan.Deactivate), partitionIDList(plan.Activate))
+	}
+	if out := plan.String(); !strings.Contains(out, "- partition 0 (8 GPUs") || !strings.Contains(out, "2 to activate, 1 to deactivate") {
+		t.Errorf("Unexpected plan output:\n%s", out)
+	}
+
+	// Partition 6 is unrelated to the desired partitions and stays active
+	plan, err = NewPlan(desired, testPartitionTable(1, 6), false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(partitionIDList(plan.Activate), []uint32{5}) ||
+		!reflect.DeepEqual(partitionIDList(plan.Unmanaged), []uint32{6}) ||
+		!reflect.DeepEqual(partitionIDList(plan.Unchanged), []uint32{1}) {
+		t.Errorf("Unexpected plan %+v", plan)
+	}
+
+	overlapping, _ := ParseDesiredState([]byte("partitions:\n  - id: 1\n  - id: 3\n"))
+	if _, err := NewPlan(overlapping, testPartitionTable(), true); err == nil {
+		t.Error("Expected overlapping desired partitions to be rejected")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	fm := newFakeManager(testPartitionTable(0))
+	desired, _ := ParseDesiredState([]byte("partitions:\n  - id: 1\n  - id: 2\n"))
+
+	if _, report, err := Reconcile(fm, desired, true); err != nil || !report.Committed {
+		t.Fatalf("Expected the desired state to be applied, got %v", err)
+	}
+	if expected := []string{"deactivate 0", "activate 1", "activate 2"}; !reflect.DeepEqual(fm.calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, fm.calls)
+	}
+
+	plan, report, err := Reconcile(fm, desired, true)
+	if err != nil || report != nil || plan.HasChanges() {
+		t.Errorf("Expected no changes on the second run, got %+v", plan)
+	}
+}
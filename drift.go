@@ -0,0 +1,156 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Kinds of partition drift
+const (
+	// DriftMissing is a partition of the majority table absent on the host
+	DriftMissing = "missing"
+	// DriftExtra is a partition of the host absent from the majority table
+	DriftExtra = "extra"
+	// DriftChanged is a partition whose GPUs differ from the majority table
+	DriftChanged = "changed"
+)
+
+// DriftGroup is a set of hosts sharing a partition table fingerprint
+type DriftGroup struct {
+	Fingerprint string   `json:"fingerprint" yaml:"fingerprint"`
+	Hosts       []string `json:"hosts" yaml:"hosts"`
+}
+
+// PartitionDrift is a partition of a host differing from the majority table.
+// GPUs are given by physical ID.
+type PartitionDrift struct {
+	ID           uint32   `json:"id" yaml:"id"`
+	Kind         string   `json:"kind" yaml:"kind"`
+	ExpectedGPUs []uint32 `json:"expectedGPUs,omitempty" yaml:"expectedGPUs,omitempty"`
+	ActualGPUs   []uint32 `json:"actualGPUs,omitempty" yaml:"actualGPUs,omitempty"`
+}
+
+// HostDrift is an outlier host and the partitions differing from the majority
+type HostDrift struct {
+	Host        string           `json:"host" yaml:"host"`
+	Fingerprint string           `json:"fingerprint" yaml:"fingerprint"`
+	Partitions  []PartitionDrift `json:"partitions" yaml:"partitions"`
+}
+
+// DriftReport groups hosts by partition table fingerprint and compares the
+// outliers with the majority
+type DriftReport struct {
+	// Groups are sorted by decreasing size, the first one being the majority
+	Groups   []DriftGroup `json:"groups" yaml:"groups"`
+	Majority string       `json:"majority,omitempty" yaml:"majority,omitempty"`
+	Outliers []HostDrift  `json:"outliers" yaml:"outliers"`
+}
+
+// HasDrift reports whether some hosts have a different partition table
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Outliers) > 0
+}
+
+// DetectDrift groups hosts by the fingerprint of their partition table, see
+// PartitionTableFingerprint. The largest group is the majority, ties going to
+// the group with the first host name, and every other host is an outlier.
+func DetectDrift(tables map[string][]Partition) *DriftReport {
+	byFingerprint := make(map[string]*DriftGroup)
+	for host, partitions := range tables {
+		fingerprint := PartitionTableFingerprint(partitions)
+		group, ok := byFingerprint[fingerprint]
+		if !ok {
+			group = &DriftGroup{Fingerprint: fingerprint}
+			byFingerprint[fingerprint] = group
+		}
+		group.Hosts = append(group.Hosts, host)
+	}
+
+	report := &DriftReport{Groups: []DriftGroup{}, Outliers: []HostDrift{}}
+	for _, group := range byFingerprint {
+		sort.Strings(group.Hosts)
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if len(a.Hosts) != len(b.Hosts) {
+			return len(a.Hosts) > len(b.Hosts)
+		}
+		return a.Hosts[0] < b.Hosts[0]
+	})
+	if len(report.Groups) == 0 {
+		return report
+	}
+
+	majority := report.Groups[0]
+	report.Majority = majority.Fingerprint
+	reference := tables[majority.Hosts[0]]
+	for _, group := range report.Groups[1:] {
+		for _, host := range group.Hosts {
+			report.Outliers = append(report.Outliers, HostDrift{
+				Host:        host,
+				Fingerprint: group.Fingerprint,
+				Partitions:  comparePartitionTables(reference, tables[host]),
+			})
+		}
+	}
+	sort.Slice(report.Outliers, func(i, j int) bool {
+		return report.Outliers[i].Host < report.Outliers[j].Host
+	})
+	return report
+}
+
+// comparePartitionTables lists the partitions of actual differing from expected, by ID
+func comparePartitionTables(expected, actual []Partition) []PartitionDrift {
+	expectedGPUs := partitionGPUSets(expected)
+	actualGPUs := partitionGPUSets(actual)
+
+	ids := make(map[uint32]struct{})
+	for id := range expectedGPUs {
+		ids[id] = struct{}{}
+	}
+	for id := range actualGPUs {
+		ids[id] = struct{}{}
+	}
+
+	drifts := []PartitionDrift{}
+	for _, id := range sortedIDs(ids) {
+		want, inExpected := expectedGPUs[id]
+		got, inActual := actualGPUs[id]
+		switch {
+		case !inActual:
+			drifts = append(drifts, PartitionDrift{ID: id, Kind: DriftMissing, ExpectedGPUs: want})
+		case !inExpected:
+			drifts = append(drifts, PartitionDrift{ID: id, Kind: DriftExtra, ActualGPUs: got})
+		case !reflect.DeepEqual(want, got):
+			drifts = append(drifts, PartitionDrift{ID: id, Kind: DriftChanged, ExpectedGPUs: want, ActualGPUs: got})
+		}
+	}
+	return drifts
+}
+
+// partitionGPUSets maps partition IDs to their sorted GPU physical IDs
+func partitionGPUSets(partitions []Partition) map[uint32][]uint32 {
+	sets := make(map[uint32][]uint32, len(partitions))
+	for _, partition := range partitions {
+		sets[partition.ID] = sortedIDs(gpuSet(partition))
+	}
+	return sets
+}
+
+// DetectDrift reads the partition table of every host and compares them.
+// Hosts that could not be read are left out of the report and returned with
+// their error in the host results.
+func (f *Fleet) DetectDrift() (*DriftReport, []HostResult) {
+	results := f.Run(func(host string, pm PartitionManager) (any, error) {
+		return pm.GetSupportedPartitions()
+	})
+
+	tables := make(map[string][]Partition)
+	for _, result := range results {
+		if result.Err == nil {
+			tables[result.Host] = result.Value.([]Partition)
+		}
+	}
+	return DetectDrift(tables), results
+}
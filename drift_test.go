@@ -0,0 +1,115 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+// driftedPartitionTable is the HGX table without partition 14 and with
+// partition 13 on GPU 7 instead of 6
+func driftedPartitionTable() []Partition {
+	partitions := []Partition{}
+	for _, partition := range testPartitionTable() {
+		switch partition.ID {
+		case 13:
+			partitions = append(partitions, testPartition(13, false, 7))
+		case 14:
+		default:
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions
+}
+
+func TestDetectDrift(t *testing.T) {
+	// UUIDs and active state do not matter
+	renamed := testPartitionTable(1)
+	for i := range renamed {
+		for j := range renamed[i].GPUs {
+			renamed[i].GPUs[j].UUID = "GPU-other"
+		}
+	}
+
+	report := DetectDrift(map[string][]Partition{
+		"node1": testPartitionTable(),
+		"node2": renamed,
+		"node3": testPartitionTable(0),
+		"node4": driftedPartitionTable(),
+	})
+
+	if len(report.Groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %+v", report.Groups)
+	}
+	if !reflect.DeepEqual(report.Groups[0].Hosts, []string{"node1", "node2", "node3"}) {
+		t.Errorf("Expected majority node1, node2, node3, got %v", report.Groups[0].Hosts)
+	}
+	if report.Majority != PartitionTableFingerprint(testPartitionTable()) {
+		t.Errorf("Expected the majority fingerprint of the HGX table, got %s", report.Majority)
+	}
+	if !report.HasDrift() || len(report.Outliers) != 1 || report.Outliers[0].Host != "node4" {
+		t.Fatalf("Expected node4 as the only outlier, got %+v", report.Outliers)
+	}
+
+	expected := []PartitionDrift{
+		{ID: 13, Kind: DriftChanged, ExpectedGPUs: []uint32{6}, ActualGPUs: []uint32{7}},
+		{ID: 14, Kind: DriftMissing, ExpectedGPUs: []uint32{7}},
+	}
+	if !reflect.DeepEqual(report.Outliers[0].Partitions, expected) {
+		t.Errorf("Expected drift %+v, got %+v", expected, report.Outliers[0].Partitions)
+	}
+}
+
+func TestDetectDriftExtraPartition(t *testing.T) {
+	extra := append(testPartitionTable(), testPartition(15, false, 0, 1, 2, 3, 4, 5))
+	report := DetectDrift(map[string][]Partition{
+		"a": testPartitionTable(),
+		"b": testPartitionTable(),
+		"c": extra,
+	})
+
+	if len(report.Outliers) != 1 {
+		t.Fatalf("Expected 1 outlier, got %+v", report.Outliers)
+	}
+	expected := []PartitionDrift{{ID: 15, Kind: DriftExtra, ActualGPUs: []uint32{0, 1, 2, 3, 4, 5}}}
+	if !reflect.DeepEqual(report.Outliers[0].Partitions, expected) {
+		t.Errorf("Expected drift %+v, got %+v", expected, report.Outliers[0].Partitions)
+	}
+}
+
+func TestDetectDriftTie(t *testing.T) {
+	report := DetectDrift(map[string][]Partition{
+		"node2": driftedPartitionTable(),
+		"node1": testPartitionTable(),
+	})
+
+	// Ties go to the group with the first host name
+	if report.Groups[0].Hosts[0] != "node1" {
+		t.Errorf("Expected node1 in the majority, got %v", report.Groups[0].Hosts)
+	}
+	if len(report.Outliers) != 1 || report.Outliers[0].Host != "node2" {
+		t.Errorf("Expected node2 as outlier, got %+v", report.Outliers)
+	}
+}
+
+func TestDetectDriftNoHosts(t *testing.T) {
+	report := DetectDrift(map[string][]Partition{})
+	if report.HasDrift() || len(report.Groups) != 0 || report.Majority != "" {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
+
+func TestFleetDetectDrift(t *testing.T) {
+	fleet, _ := newTestFleet([]string{"node1", "node2", "node3", "node4"}, map[string]*fakeManager{
+		"node1": newFakeManager(testPartitionTable()),
+		"node2": newFakeManager(testPartitionTable()),
+		"node4": newFakeManager(driftedPartitionTable()),
+	})
+
+	report, results := fleet.DetectDrift()
+	if results[2].Err == nil {
+		t.Error("Expected an error for the unreachable node3")
+	}
+	if len(report.Groups) != 2 || len(report.Outliers) != 1 || report.Outliers[0].Host != "node4" {
+		t.Errorf("Expected node4 as the only outlier, got %+v", report)
+	}
+}
@@ -11,7 +11,7 @@ package fabricmanager
 import "C"
 import (
 	"fmt"
-	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -136,51 +136,51 @@ func IsPartitionError(err error) bool {
 
 // PCI Device information
 type PCIDevice struct {
-	Domain   uint32
-	Bus      uint32
-	Device   uint32
-	Function uint32
+	Domain   uint32 `json:"domain" yaml:"domain"`
+	Bus      uint32 `json:"bus" yaml:"bus"`
+	Device   uint32 `json:"device" yaml:"device"`
+	Function uint32 `json:"function" yaml:"function"`
 }
 
 // GPU information within a partition
 type PartitionGPUInfo struct {
-	PhysicalID          uint32
-	UUID                string
-	PCIBusID            string
-	NumNvLinksAvailable uint32
-	MaxNumNvLinks       uint32
-	NvlinkLineRateMBps  uint32
+	PhysicalID          uint32 `json:"physicalId" yaml:"physicalId"`
+	UUID                string `json:"uuid" yaml:"uuid"`
+	PCIBusID            string `json:"pciBusId" yaml:"pciBusId"`
+	NumNvLinksAvailable uint32 `json:"numNvLinksAvailable" yaml:"numNvLinksAvailable"`
+	MaxNumNvLinks       uint32 `json:"maxNumNvLinks" yaml:"maxNumNvLinks"`
+	NvlinkLineRateMBps  uint32 `json:"nvlinkLineRateMBps" yaml:"nvlinkLineRateMBps"`
 }
 
 // Fabric partition information
 type Partition struct {
-	ID       uint32
-	IsActive bool
-	NumGPUs  uint32
-	GPUs     []PartitionGPUInfo
+	ID       uint32             `json:"id" yaml:"id"`
+	IsActive bool               `json:"isActive" yaml:"isActive"`
+	NumGPUs  uint32             `json:"numGPUs" yaml:"numGPUs"`
+	GPUs     []PartitionGPUInfo `json:"gpus" yaml:"gpus"`
 }
 
 // NVLink failed device information
 type NvlinkFailedDeviceInfo struct {
-	UUID     string
-	PCIBusID string
-	NumPorts uint32
-	PortNums []uint32
+	UUID     string   `json:"uuid" yaml:"uuid"`
+	PCIBusID string   `json:"pciBusId" yaml:"pciBusId"`
+	NumPorts uint32   `json:"numPorts" yaml:"numPorts"`
+	PortNums []uint32 `json:"portNums" yaml:"portNums"`
 }
 
 // NVLink failed devices
 type NvlinkFailedDevices struct {
-	NumGPUs     uint32
-	NumSwitches uint32
-	GPUInfo     []NvlinkFailedDeviceInfo
-	SwitchInfo  []NvlinkFailedDeviceInfo
+	NumGPUs     uint32                   `json:"numGPUs" yaml:"numGPUs"`
+	NumSwitches uint32                   `json:"numSwitches" yaml:"numSwitches"`
+	GPUInfo     []NvlinkFailedDeviceInfo `json:"gpuInfo" yaml:"gpuInfo"`
+	SwitchInfo  []NvlinkFailedDeviceInfo `json:"switchInfo" yaml:"switchInfo"`
 }
 
 // Unsupported partition information
 type UnsupportedPartition struct {
-	ID             uint32
-	NumGPUs        uint32
-	GPUPhysicalIDs []uint32
+	ID             uint32   `json:"id" yaml:"id"`
+	NumGPUs        uint32   `json:"numGPUs" yaml:"numGPUs"`
+	GPUPhysicalIDs []uint32 `json:"gpuPhysicalIds" yaml:"gpuPhysicalIds"`
 }
 
 // Type aliases for C typedefs
@@ -217,9 +217,28 @@ type (
 
 // Client represents a connection to FabricManager
 type Client struct {
-	handle C.fmHandle_t
+	handle  C.fmHandle_t
+	address string
+	// tunnel is the SSH tunnel of a client created by ConnectSSH
+	tunnel *SSHTunnel
+	// actor is recorded in the audit events of the client
+	actor string
 }
 
+// PartitionManager is the set of partition operations provided by Client.
+// The higher-level helpers of this package accept it so they can be used
+// with other implementations as well.
+type PartitionManager interface {
+	GetSupportedPartitions() ([]Partition, error)
+	ActivatePartition(id uint32) error
+	DeactivatePartition(id uint32) error
+	GetNvlinkFailedDevices() (*NvlinkFailedDevices, error)
+	GetUnsupportedPartitions() ([]UnsupportedPartition, error)
+	SetActivatedPartitions(ids []uint32) error
+}
+
+var _ PartitionManager = (*Client)(nil)
+
 // convertReturnCode converts C return code to Go error
 func convertReturnCode(code C.fmReturn_t) error {
 	if code == C.FM_ST_SUCCESS {
@@ -299,7 +318,7 @@ func Shutdown() error {
 // Connect connects to a FabricManager instance
 func Connect(address string, timeoutMs int) (*Client, error) {
 	// Parse address to determine if it's a Unix socket or TCP
-	isUnixSocket := strings.HasPrefix(address, "/") || strings.Contains(address, ".sock")
+	isUnixSocket := isUnixSocketAddress(address)
 
 	// Create connection parameters
 	params := C.fmConnectParams_t{
@@ -325,12 +344,21 @@ func Connect(address string, timeoutMs int) (*Client, error) {
 		return nil, convertReturnCode(ret)
 	}
 
-	return &Client{handle: handle}, nil
+	return &Client{handle: handle, address: address}, nil
+}
+
+// SetAuditActor records actor, such as an API client, in the audit events of
+// the partition changes made through the client
+func (c *Client) SetAuditActor(actor string) {
+	c.actor = actor
 }
 
-// Disconnect disconnects from the FabricManager instance
+// Disconnect disconnects from the FabricManager instance, closing its SSH tunnel if any
 func (c *Client) Disconnect() error {
 	ret := C.fmDisconnect(c.handle)
+	if c.tunnel != nil {
+		c.tunnel.Close()
+	}
 	return convertReturnCode(ret)
 }
 
@@ -375,14 +403,20 @@ func (c *Client) GetSupportedPartitions() ([]Partition, error) {
 
 // ActivatePartition activates a fabric partition
 func (c *Client) ActivatePartition(id uint32) error {
+	start := time.Now()
 	ret := C.fmActivateFabricPartition(c.handle, C.fmFabricPartitionId_t(id))
-	return convertReturnCode(ret)
+	err := convertReturnCode(ret)
+	audit(AuditActivate, c.address, c.actor, []uint32{id}, start, err)
+	return err
 }
 
 // DeactivatePartition deactivates a fabric partition
 func (c *Client) DeactivatePartition(id uint32) error {
+	start := time.Now()
 	ret := C.fmDeactivateFabricPartition(c.handle, C.fmFabricPartitionId_t(id))
-	return convertReturnCode(ret)
+	err := convertReturnCode(ret)
+	audit(AuditDeactivate, c.address, c.actor, []uint32{id}, start, err)
+	return err
 }
 
 // GetNvlinkFailedDevices gets information about NVLink failed devices
@@ -473,6 +507,9 @@ func (c *Client) SetActivatedPartitions(ids []uint32) error {
 		activatedList.partitionIds[i] = C.fmFabricPartitionId_t(id)
 	}
 
+	start := time.Now()
 	ret := C.fmSetActivatedFabricPartitions(c.handle, (*C.fmActivatedFabricPartitionList_t)(unsafe.Pointer(&activatedList)))
-	return convertReturnCode(ret)
+	err := convertReturnCode(ret)
+	audit(AuditSetActivated, c.address, c.actor, ids, start, err)
+	return err
 }
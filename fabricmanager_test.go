@@ -1,7 +1,11 @@
 package fabricmanager
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestErrorTypes(t *testing.T) {
@@ -140,6 +144,41 @@ func TestNewTypes(t *testing.T) {
 	_ = unsupportedList // Just verify it can be instantiated
 }
 
+func TestStableFieldNames(t *testing.T) {
+	partition := testPartition(1, true, 0, 1)
+
+	data, err := json.Marshal(partition)
+	if err != nil {
+		t.Fatalf("Failed to encode partition as JSON: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"id", "isActive", "numGPUs", "gpus"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("Expected JSON field %q, got %s", name, data)
+		}
+	}
+	gpu := fields["gpus"].([]any)[0].(map[string]any)
+	for _, name := range []string{"physicalId", "uuid", "pciBusId", "numNvLinksAvailable", "maxNumNvLinks", "nvlinkLineRateMBps"} {
+		if _, ok := gpu[name]; !ok {
+			t.Errorf("Expected JSON GPU field %q, got %s", name, data)
+		}
+	}
+
+	// YAML uses the same names
+	out, err := yaml.Marshal(partition)
+	if err != nil {
+		t.Fatalf("Failed to encode partition as YAML: %v", err)
+	}
+	for _, name := range []string{"id:", "isActive:", "numGPUs:", "gpus:", "physicalId:", "pciBusId:"} {
+		if !strings.Contains(string(out), name) {
+			t.Errorf("Expected YAML field %q, got:\n%s", name, out)
+		}
+	}
+}
+
 func TestMakeFMParamVersion(t *testing.T) {
 	// Test the helper function for creating version numbers
 	result := makeFMParamVersion(16, 1)
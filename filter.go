@@ -0,0 +1,368 @@
+package fabricmanager
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PartitionFilter selects partitions
+type PartitionFilter func(partition Partition) bool
+
+// FilterPartitions returns the partitions selected by filter, in their original order
+func FilterPartitions(partitions []Partition, filter PartitionFilter) []Partition {
+	selected := []Partition{}
+	for _, partition := range partitions {
+		if filter == nil || filter(partition) {
+			selected = append(selected, partition)
+		}
+	}
+	return selected
+}
+
+// IsActiveFilter selects active partitions, or inactive ones if active is false
+func IsActiveFilter(active bool) PartitionFilter {
+	return func(partition Partition) bool {
+		return partition.IsActive == active
+	}
+}
+
+// NumGPUsFilter selects partitions with exactly numGPUs GPUs
+func NumGPUsFilter(numGPUs int) PartitionFilter {
+	return func(partition Partition) bool {
+		return len(partition.GPUs) == numGPUs
+	}
+}
+
+// ContainsGPUFilter selects partitions including a GPU given by UUID, PCI bus
+// ID or physical ID
+func ContainsGPUFilter(gpu string) PartitionFilter {
+	gpu = strings.TrimSpace(gpu)
+	physicalID, err := strconv.ParseUint(gpu, 10, 32)
+	isPhysicalID := err == nil
+	busID := normalizePCIBusID(gpu)
+
+	return func(partition Partition) bool {
+		for _, info := range partition.GPUs {
+			switch {
+			case isPhysicalID && uint64(info.PhysicalID) == physicalID:
+				return true
+			case strings.EqualFold(info.UUID, gpu):
+				return true
+			case info.PCIBusID != "" && normalizePCIBusID(info.PCIBusID) == busID:
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// DegradedFilter selects partitions with a GPU having fewer NVLinks available than its maximum
+func DegradedFilter() PartitionFilter {
+	return func(partition Partition) bool {
+		for _, gpu := range partition.GPUs {
+			if gpu.NumNvLinksAvailable < gpu.MaxNumNvLinks {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllOf selects partitions selected by every filter
+func AllOf(filters ...PartitionFilter) PartitionFilter {
+	return func(partition Partition) bool {
+		for _, filter := range filters {
+			if !filter(partition) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf selects partitions selected by at least one filter
+func AnyOf(filters ...PartitionFilter) PartitionFilter {
+	return func(partition Partition) bool {
+		for _, filter := range filters {
+			if filter(partition) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not selects partitions not selected by filter
+func Not(filter PartitionFilter) PartitionFilter {
+	return func(partition Partition) bool {
+		return !filter(partition)
+	}
+}
+
+// Sort keys accepted by SortPartitions
+const (
+	SortByID          = "id"
+	SortByGPUs        = "gpus"
+	SortByBandwidth   = "bandwidth"
+	SortByDegradation = "degradation"
+	SortByState       = "state"
+)
+
+// SortKeys lists the keys accepted by SortPartitions
+var SortKeys = []string{SortByID, SortByGPUs, SortByBandwidth, SortByDegradation, SortByState}
+
+// SortPartitions returns a copy of partitions sorted by key, ascending unless
+// the key is prefixed with "-". Ties are broken by partition ID. The bandwidth
+// key sorts by effective bandwidth and state puts active partitions first.
+func SortPartitions(partitions []Partition, key string) ([]Partition, error) {
+	descending := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+
+	var less func(a, b Partition) bool
+	switch key {
+	case SortByID:
+		less = func(a, b Partition) bool { return a.ID < b.ID }
+	case SortByGPUs:
+		less = func(a, b Partition) bool { return len(a.GPUs) < len(b.GPUs) }
+	case SortByBandwidth:
+		less = func(a, b Partition) bool { return a.EffectiveBandwidthMBps() < b.EffectiveBandwidthMBps() }
+	case SortByDegradation:
+		less = func(a, b Partition) bool { return a.DegradationPercent() < b.DegradationPercent() }
+	case SortByState:
+		less = func(a, b Partition) bool { return a.IsActive && !b.IsActive }
+	default:
+		return nil, fmt.Errorf("unknown sort key %q: must be one of %s", key, strings.Join(SortKeys, ", "))
+	}
+
+	sorted := make([]Partition, len(partitions))
+	copy(sorted, partitions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if descending {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted, nil
+}
+
+// ParseFilter parses a filter expression combining conditions with &&, ||,
+// ! and parentheses. Conditions are:
+//
+//	active, inactive, degraded
+//	id <op> N, gpus <op> N, bandwidth <op> MBps, degradation <op> percent
+//	gpu = <uuid|busid|physid>, gpu != <uuid|busid|physid>
+//
+// where <op> is one of =, ==, !=, <, <=, > or >=. For example:
+//
+//	active && gpus >= 4 && !degraded
+func ParseFilter(expr string) (PartitionFilter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos])
+	}
+	return filter, nil
+}
+
+// tokenizeFilter splits a filter expression into operators, parentheses and words
+func tokenizeFilter(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"),
+			strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="),
+			strings.HasPrefix(expr[i:], "<="), strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case strings.ContainsRune("()!<>=", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '&' || c == '|':
+			return nil, fmt.Errorf("invalid operator %q in filter, use && or ||", c)
+		default:
+			j := i
+			for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("()!<>=&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser for filter expressions
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *filterParser) parseOr() (PartitionFilter, error) {
+	filters := []PartitionFilter{}
+	for {
+		filter, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+		if p.peek() != "||" {
+			break
+		}
+		p.next()
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return AnyOf(filters...), nil
+}
+
+func (p *filterParser) parseAnd() (PartitionFilter, error) {
+	filters := []PartitionFilter{}
+	for {
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+		if p.peek() != "&&" {
+			break
+		}
+		p.next()
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return AllOf(filters...), nil
+}
+
+func (p *filterParser) parseUnary() (PartitionFilter, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(filter), nil
+	case "(":
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		return filter, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *filterParser) parseCondition() (PartitionFilter, error) {
+	name := strings.ToLower(p.next())
+	switch name {
+	case "":
+		return nil, fmt.Errorf("unexpected end of filter")
+	case "active":
+		return IsActiveFilter(true), nil
+	case "inactive":
+		return IsActiveFilter(false), nil
+	case "degraded":
+		return DegradedFilter(), nil
+	}
+
+	op := p.next()
+	if op == "=" {
+		op = "=="
+	}
+	if op != "==" && op != "!=" && op != "<" && op != "<=" && op != ">" && op != ">=" {
+		return nil, fmt.Errorf("expected a comparison after %q in filter", name)
+	}
+	value := p.next()
+	if value == "" {
+		return nil, fmt.Errorf("missing value after %q %s in filter", name, op)
+	}
+
+	if name == "gpu" {
+		filter := ContainsGPUFilter(value)
+		switch op {
+		case "==":
+			return filter, nil
+		case "!=":
+			return Not(filter), nil
+		}
+		return nil, fmt.Errorf("gpu only supports = and != in filter")
+	}
+
+	var field func(partition Partition) float64
+	switch name {
+	case "id":
+		field = func(partition Partition) float64 { return float64(partition.ID) }
+	case "gpus":
+		field = func(partition Partition) float64 { return float64(len(partition.GPUs)) }
+	case "bandwidth":
+		field = func(partition Partition) float64 { return float64(partition.EffectiveBandwidthMBps()) }
+	case "degradation":
+		field = func(partition Partition) float64 { return partition.DegradationPercent() }
+	default:
+		return nil, fmt.Errorf("unknown field %q in filter", name)
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q for %s in filter", value, name)
+	}
+	return func(partition Partition) bool {
+		v := field(partition)
+		switch op {
+		case "==":
+			return v == n
+		case "!=":
+			return v != n
+		case "<":
+			return v < n
+		case "<=":
+			return v <= n
+		case ">":
+			return v > n
+		default:
+			return v >= n
+		}
+	}, nil
+}
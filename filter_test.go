@@ -0,0 +1,121 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+// degradedTable returns the HGX table with partitions 1 and 2 active and
+// GPU 5 down to 12 of 18 NVLinks
+func degradedTable() []Partition {
+	partitions := testPartitionTable(1, 2)
+	for i := range partitions {
+		for j := range partitions[i].GPUs {
+			if partitions[i].GPUs[j].PhysicalID == 5 {
+				partitions[i].GPUs[j].NumNvLinksAvailable = 12
+			}
+		}
+	}
+	return partitions
+}
+
+func TestPartitionFilters(t *testing.T) {
+	partitions := degradedTable()
+
+	tests := []struct {
+		name     string
+		filter   PartitionFilter
+		expected []uint32
+	}{
+		{"active", IsActiveFilter(true), []uint32{1, 2}},
+		{"4 GPUs", NumGPUsFilter(4), []uint32{1, 2}},
+		{"GPU by physical ID", ContainsGPUFilter("3"), []uint32{0, 1, 4, 10}},
+		{"GPU by UUID", ContainsGPUFilter("gpu-00000003"), []uint32{0, 1, 4, 10}},
+		{"GPU by bus ID", ContainsGPUFilter("0000:13:00.0"), []uint32{0, 1, 4, 10}},
+		{"degraded", DegradedFilter(), []uint32{0, 2, 5, 12}},
+		{"inactive 2-GPU", AllOf(IsActiveFilter(false), NumGPUsFilter(2)), []uint32{3, 4, 5, 6}},
+		{"1 or 8 GPUs, not degraded", AllOf(AnyOf(NumGPUsFilter(1), NumGPUsFilter(8)), Not(DegradedFilter())), []uint32{7, 8, 9, 10, 11, 13, 14}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ids := partitionIDList(FilterPartitions(partitions, tt.filter)); !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	partitions := degradedTable()
+
+	tests := []struct {
+		expr     string
+		expected []uint32
+	}{
+		{"active", []uint32{1, 2}},
+		{"active && !degraded", []uint32{1}},
+		{"gpus >= 4 && inactive", []uint32{0}},
+		{"gpus=2 && (id<4 || degraded)", []uint32{3, 5}},
+		{"gpu = GPU-00000005 && gpus <= 2", []uint32{5, 12}},
+		{"gpu != 0 && gpus == 4", []uint32{2}},
+		{"degradation > 5", []uint32{2, 5, 12}},
+		{"!(gpus < 4)", []uint32{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+			if ids := partitionIDList(FilterPartitions(partitions, filter)); !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"active &&",
+		"(active",
+		"gpus",
+		"gpus >= many",
+		"color = red",
+		"gpu > 3",
+		"active & degraded",
+		"active inactive",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("Expected an error for filter %q", expr)
+		}
+	}
+}
+
+func TestSortPartitions(t *testing.T) {
+	partitions := degradedTable()
+
+	sorted, err := SortPartitions(partitions, "-gpus")
+	if err != nil {
+		t.Fatalf("Failed to sort: %v", err)
+	}
+	if ids := partitionIDList(sorted)[:4]; !reflect.DeepEqual(ids, []uint32{0, 1, 2, 3}) {
+		t.Errorf("Expected largest partitions first, got %v", ids)
+	}
+
+	sorted, _ = SortPartitions(FilterPartitions(partitions, NumGPUsFilter(4)), "bandwidth")
+	if ids := partitionIDList(sorted); !reflect.DeepEqual(ids, []uint32{2, 1}) {
+		t.Errorf("Expected the degraded partition first by ascending bandwidth, got %v", ids)
+	}
+
+	sorted, _ = SortPartitions(partitions, "state")
+	if ids := partitionIDList(sorted)[:3]; !reflect.DeepEqual(ids, []uint32{1, 2, 0}) {
+		t.Errorf("Expected active partitions first, got %v", ids)
+	}
+
+	if _, err := SortPartitions(partitions, "color"); err == nil {
+		t.Error("Expected an error for an unknown sort key")
+	}
+}
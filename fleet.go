@@ -0,0 +1,193 @@
+package fabricmanager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFleetParallelism is the default number of hosts a Fleet handles at once
+const DefaultFleetParallelism = 16
+
+// ErrHostTimeout is returned for a host that did not complete within the fleet timeout
+var ErrHostTimeout = errors.New("host timed out")
+
+// HostConnection is a connection to the FabricManager of one host
+type HostConnection interface {
+	PartitionManager
+	Disconnect() error
+}
+
+// HostResult is the outcome of a fleet operation on one host
+type HostResult struct {
+	Host     string
+	Value    any
+	Err      error
+	Duration time.Duration
+}
+
+// Fleet runs operations concurrently against the FabricManager of many hosts
+type Fleet struct {
+	Hosts []string
+	// Parallelism is the maximum number of hosts handled at once
+	Parallelism int
+	// Timeout bounds the time spent on each host, including connecting, 0 for no limit
+	Timeout time.Duration
+	// Connect connects to the FabricManager of a host
+	Connect func(host string) (HostConnection, error)
+}
+
+// NewFleet creates a fleet connecting to each host with ConnectHost
+func NewFleet(hosts []string, timeoutMs int) *Fleet {
+	return &Fleet{
+		Hosts:       hosts,
+		Parallelism: DefaultFleetParallelism,
+		Connect: func(host string) (HostConnection, error) {
+			return ConnectHost(host, timeoutMs)
+		},
+	}
+}
+
+// ConnectHost connects to the FabricManager of a host, on the default port
+// unless the host includes one
+func ConnectHost(host string, timeoutMs int) (HostConnection, error) {
+	client, err := Connect(HostAddress(host, FM_CMD_PORT_NUMBER), timeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// HostAddress returns the FabricManager address of a host, adding port if
+// the host has none. UNIX socket paths are returned unchanged.
+func HostAddress(host string, port int) string {
+	if strings.HasPrefix(host, "/") {
+		return host
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+}
+
+// Run calls fn with a connection to each host and returns the results in the
+// order of the hosts. A host failing to connect, failing or timing out gets an
+// error result without affecting the others. Calls still running on a host
+// that timed out complete in the background before its connection is closed,
+// and keep counting against Parallelism until then.
+func (f *Fleet) Run(fn func(host string, pm PartitionManager) (any, error)) []HostResult {
+	return f.run(fn, false)
+}
+
+// run is Run, also waiting for the calls still running on hosts that timed
+// out if join is set
+func (f *Fleet) run(fn func(host string, pm PartitionManager) (any, error), join bool) []HostResult {
+	parallelism := f.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultFleetParallelism
+	}
+
+	results := make([]HostResult, len(f.Hosts))
+	slots := make(chan struct{}, parallelism)
+	var wg, workers sync.WaitGroup
+	for i, host := range f.Hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			workers.Add(1)
+			results[i] = f.runHost(host, fn, func() {
+				<-slots
+				workers.Done()
+			})
+		}(i, host)
+	}
+	wg.Wait()
+	if join {
+		workers.Wait()
+	}
+	return results
+}
+
+// runHost runs fn on one host within the fleet timeout. done is called once
+// the call completes, which may be after runHost returned on a timeout.
+func (f *Fleet) runHost(host string, fn func(host string, pm PartitionManager) (any, error), done func()) HostResult {
+	start := time.Now()
+	results := make(chan HostResult, 1)
+	go func() {
+		defer done()
+		conn, err := f.Connect(host)
+		if err != nil {
+			results <- HostResult{Host: host, Err: fmt.Errorf("failed to connect to %s: %w", host, err)}
+			return
+		}
+		defer conn.Disconnect()
+
+		value, err := fn(host, conn)
+		results <- HostResult{Host: host, Value: value, Err: err}
+	}()
+
+	var result HostResult
+	if f.Timeout > 0 {
+		timer := time.NewTimer(f.Timeout)
+		defer timer.Stop()
+		select {
+		case result = <-results:
+		case <-timer.C:
+			result = HostResult{Host: host, Err: fmt.Errorf("%w after %s", ErrHostTimeout, f.Timeout)}
+		}
+	} else {
+		result = <-results
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// ParseHosts splits a list of hosts separated by commas or whitespace,
+// dropping duplicates
+func ParseHosts(list string) []string {
+	return uniqueHosts(strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}))
+}
+
+// LoadInventory reads an inventory file listing one host per line. Blank
+// lines and # comments are ignored, as are words following the host name.
+func LoadInventory(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if fields := strings.Fields(line); len(fields) > 0 {
+			hosts = append(hosts, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return uniqueHosts(hosts), nil
+}
+
+func uniqueHosts(hosts []string) []string {
+	seen := make(map[string]bool)
+	unique := []string{}
+	for _, host := range hosts {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			unique = append(unique, host)
+		}
+	}
+	return unique
+}
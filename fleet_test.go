@@ -0,0 +1,230 @@
+package fabricmanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeHost is a HostConnection to a fakeManager
+type fakeHost struct {
+	*fakeManager
+	disconnected *atomic.Int32
+}
+
+func (h fakeHost) Disconnect() error {
+	h.disconnected.Add(1)
+	return nil
+}
+
+// newTestFleet creates a fleet of fake managers, hosts missing from managers
+// failing to connect
+func newTestFleet(hosts []string, managers map[string]*fakeManager) (*Fleet, *atomic.Int32) {
+	disconnected := &atomic.Int32{}
+	return &Fleet{
+		Hosts: hosts,
+		Connect: func(host string) (HostConnection, error) {
+			fm, ok := managers[host]
+			if !ok {
+				return nil, &FMError{Code: FM_ST_CONNECTION_NOT_VALID, Message: "Connection not valid"}
+			}
+			return fakeHost{fm, disconnected}, nil
+		},
+	}, disconnected
+}
+
+func TestFleetRun(t *testing.T) {
+	managers := map[string]*fakeManager{
+		"node1": newFakeManager(testPartitionTable(1)),
+		"node3": newFakeManager(testPartitionTable(2, 3)),
+	}
+	fleet, disconnected := newTestFleet([]string{"node1", "node2", "node3"}, managers)
+
+	results := fleet.Run(func(host string, pm PartitionManager) (any, error) {
+		partitions, err := pm.GetSupportedPartitions()
+		if err != nil {
+			return nil, err
+		}
+		return partitionIDList(FilterPartitions(partitions, IsActiveFilter(true))), nil
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, host := range fleet.Hosts {
+		if results[i].Host != host {
+			t.Errorf("Expected result %d for %s, got %s", i, host, results[i].Host)
+		}
+	}
+	if results[0].Err != nil || !reflect.DeepEqual(results[0].Value, []uint32{1}) {
+		t.Errorf("Expected node1 active partitions [1], got %v, %v", results[0].Value, results[0].Err)
+	}
+	var fmErr *FMError
+	if !errors.As(results[1].Err, &fmErr) || !IsConnectionError(fmErr) {
+		t.Errorf("Expected node2 connection error, got %v", results[1].Err)
+	}
+	if results[2].Err != nil || !reflect.DeepEqual(results[2].Value, []uint32{2, 3}) {
+		t.Errorf("Expected node3 active partitions [2 3], got %v, %v", results[2].Value, results[2].Err)
+	}
+	if disconnected.Load() != 2 {
+		t.Errorf("Expected 2 disconnections, got %d", disconnected.Load())
+	}
+}
+
+func TestFleetRunParallelism(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	managers := map[string]*fakeManager{}
+	for _, host := range hosts {
+		managers[host] = newFakeManager(testPartitionTable())
+	}
+	fleet, _ := newTestFleet(hosts, managers)
+	fleet.Parallelism = 3
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	fleet.Run(func(host string, pm PartitionManager) (any, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	})
+
+	if peak > 3 {
+		t.Errorf("Expected at most 3 hosts at once, got %d", peak)
+	}
+}
+
+func TestFleetRunTimeout(t *testing.T) {
+	managers := map[string]*fakeManager{
+		"fast": newFakeManager(testPartitionTable()),
+		"slow": newFakeManager(testPartitionTable()),
+	}
+	fleet, _ := newTestFleet([]string{"fast", "slow"}, managers)
+	fleet.Timeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	results := fleet.Run(func(host string, pm PartitionManager) (any, error) {
+		if host == "slow" {
+			<-release
+		}
+		return host, nil
+	})
+
+	if results[0].Err != nil || results[0].Value != "fast" {
+		t.Errorf("Expected fast host to succeed, got %v, %v", results[0].Value, results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrHostTimeout) {
+		t.Errorf("Expected slow host to time out, got %v", results[1].Err)
+	}
+}
+
+func TestFleetRunTimeoutKeepsSlot(t *testing.T) {
+	hosts := []string{"hung1", "hung2", "a", "b"}
+	managers := map[string]*fakeManager{}
+	for _, host := range hosts {
+		managers[host] = newFakeManager(testPartitionTable())
+	}
+	fleet, _ := newTestFleet(hosts, managers)
+	fleet.Parallelism = 2
+	fleet.Timeout = 20 * time.Millisecond
+
+	// Hosts timing out hold their slot until their call completes
+	release := make(chan struct{})
+	var mu sync.Mutex
+	running, peak := 0, 0
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+	}()
+	results := fleet.Run(func(host string, pm PartitionManager) (any, error) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		if host == "hung1" || host == "hung2" {
+			<-release
+		}
+		return host, nil
+	})
+
+	timedOut := 0
+	for _, result := range results {
+		if errors.Is(result.Err, ErrHostTimeout) {
+			timedOut++
+		}
+	}
+	if timedOut < 2 {
+		t.Errorf("Expected the hung hosts to time out, got %d timeouts", timedOut)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if peak > 2 {
+		t.Errorf("Expected at most 2 calls at once, got %d", peak)
+	}
+}
+
+func TestHostAddress(t *testing.T) {
+	tests := map[string]string{
+		"node1":             "node1:6666",
+		"node1:7000":        "node1:7000",
+		"10.0.0.1":          "10.0.0.1:6666",
+		"::1":               "[::1]:6666",
+		"[fe80::1]":         "[fe80::1]:6666",
+		"[fe80::1]:7000":    "[fe80::1]:7000",
+		"/run/fm/fm.socket": "/run/fm/fm.socket",
+	}
+	for host, expected := range tests {
+		if got := HostAddress(host, FM_CMD_PORT_NUMBER); got != expected {
+			t.Errorf("HostAddress(%q): expected %q, got %q", host, expected, got)
+		}
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	hosts := ParseHosts("node1,node2 node3,,node1\tnode4")
+	expected := []string{"node1", "node2", "node3", "node4"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected %v, got %v", expected, hosts)
+	}
+}
+
+func TestLoadInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	content := "# rack 1\nnode1\nnode2 rack=1  # spare\n\n  node3:7000\nnode1\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := LoadInventory(path)
+	if err != nil {
+		t.Fatalf("Failed to load inventory: %v", err)
+	}
+	expected := []string{"node1", "node2", "node3:7000"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected %v, got %v", expected, hosts)
+	}
+
+	if _, err := LoadInventory(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing inventory")
+	}
+}
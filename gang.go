@@ -0,0 +1,116 @@
+package fabricmanager
+
+import (
+	"fmt"
+	"sync"
+)
+
+// GangHost is the outcome of a gang activation on one host. Status is
+// StepApplied for a host that kept its partition, StepFailed for a host that
+// could not activate one, and StepRolledBack or StepRollbackFailed for a host
+// whose partition was deactivated, or failed to be, after a host failed. A host
+// that activated its partition after timing out keeps its timeout in Error.
+type GangHost struct {
+	Host       string      `json:"host" yaml:"host"`
+	Status     StepStatus  `json:"status" yaml:"status"`
+	Allocation *Allocation `json:"allocation,omitempty" yaml:"allocation,omitempty"`
+	Error      string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// GangReport describes a gang activation, one entry per host in fleet order
+type GangReport struct {
+	NumGPUs   int        `json:"numGPUs" yaml:"numGPUs"`
+	Hosts     []GangHost `json:"hosts" yaml:"hosts"`
+	Committed bool       `json:"committed" yaml:"committed"`
+}
+
+// GangActivate activates a partition of numGPUs GPUs on every host as a unit.
+// Partitions are selected and activated with Allocate on all hosts in
+// parallel. If any host fails, the partitions activated on the other hosts are
+// deactivated. Calls still running on hosts that timed out are waited for
+// before deciding, so a partition activated after its host timed out is rolled
+// back too. The report lists every host, whether or not an error is returned.
+func (f *Fleet) GangActivate(numGPUs int) (*GangReport, error) {
+	report := &GangReport{NumGPUs: numGPUs, Hosts: []GangHost{}}
+	if numGPUs <= 0 {
+		return report, fmt.Errorf("invalid number of GPUs: %d", numGPUs)
+	}
+	if len(f.Hosts) == 0 {
+		return report, fmt.Errorf("no hosts to activate")
+	}
+
+	// Allocations are also kept aside to catch hosts completing after their timeout
+	var mu sync.Mutex
+	allocations := make(map[string]*Allocation)
+	results := f.run(func(host string, pm PartitionManager) (any, error) {
+		allocation, err := Allocate(pm, numGPUs)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		allocations[host] = allocation
+		mu.Unlock()
+		return allocation, nil
+	}, true)
+
+	var firstErr error
+	failed := 0
+	mu.Lock()
+	for _, result := range results {
+		host := GangHost{Host: result.Host, Status: StepApplied, Allocation: allocations[result.Host]}
+		if result.Err != nil {
+			host.Status = StepFailed
+			host.Error = result.Err.Error()
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to activate a %d-GPU partition on %s: %w", numGPUs, result.Host, result.Err)
+			}
+		}
+		report.Hosts = append(report.Hosts, host)
+	}
+	mu.Unlock()
+	if failed == 0 {
+		report.Committed = true
+		return report, nil
+	}
+
+	stepErr := fmt.Errorf("gang activation failed on %d of %d hosts: %w", failed, len(f.Hosts), firstErr)
+	if rollbackErr := f.rollbackGang(report); rollbackErr != nil {
+		return report, fmt.Errorf("%w; rollback incomplete: %v", stepErr, rollbackErr)
+	}
+	return report, stepErr
+}
+
+// rollbackGang deactivates the partitions activated by a gang activation
+func (f *Fleet) rollbackGang(report *GangReport) error {
+	rollback := *f
+	rollback.Hosts = nil
+	activated := make(map[string]*GangHost)
+	for i := range report.Hosts {
+		if host := &report.Hosts[i]; host.Allocation != nil {
+			rollback.Hosts = append(rollback.Hosts, host.Host)
+			activated[host.Host] = host
+		}
+	}
+	if len(rollback.Hosts) == 0 {
+		return nil
+	}
+
+	var firstErr error
+	results := rollback.Run(func(host string, pm PartitionManager) (any, error) {
+		return nil, pm.DeactivatePartition(activated[host].Allocation.PartitionID)
+	})
+	for _, result := range results {
+		host := activated[result.Host]
+		if result.Err != nil {
+			host.Status = StepRollbackFailed
+			host.Error = result.Err.Error()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to deactivate partition %d on %s: %w", host.Allocation.PartitionID, result.Host, result.Err)
+			}
+			continue
+		}
+		host.Status = StepRolledBack
+	}
+	return firstErr
+}
@@ -0,0 +1,158 @@
+package fabricmanager
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowHost is a fakeHost whose activations take delay
+type slowHost struct {
+	fakeHost
+	delay time.Duration
+}
+
+func (h slowHost) ActivatePartition(id uint32) error {
+	time.Sleep(h.delay)
+	return h.fakeHost.ActivatePartition(id)
+}
+
+func TestFleetGangActivate(t *testing.T) {
+	managers := map[string]*fakeManager{
+		"node1": newFakeManager(testPartitionTable()),
+		"node2": newFakeManager(testPartitionTable(1)),
+	}
+	fleet, _ := newTestFleet([]string{"node1", "node2"}, managers)
+
+	report, err := fleet.GangActivate(4)
+	if err != nil {
+		t.Fatalf("Failed to gang activate: %v", err)
+	}
+	if !report.Committed || len(report.Hosts) != 2 {
+		t.Fatalf("Expected a committed report for 2 hosts, got %+v", report)
+	}
+	for _, host := range report.Hosts {
+		if host.Status != StepApplied || host.Allocation == nil || len(host.Allocation.GPUUUIDs) != 4 {
+			t.Errorf("Expected 4 GPUs applied on %s, got %+v", host.Host, host)
+		}
+	}
+	if report.Hosts[1].Allocation.PartitionID != 2 {
+		t.Errorf("Expected partition 2 next to the active partition 1 on node2, got %d", report.Hosts[1].Allocation.PartitionID)
+	}
+}
+
+func TestFleetGangActivateRollback(t *testing.T) {
+	nvlinkErr := &FMError{Code: FM_ST_NVLINK_ERROR, Message: "NVLink error"}
+	managers := map[string]*fakeManager{
+		"node1": newFakeManager(testPartitionTable()),
+		"node2": newFakeManager(testPartitionTable()),
+		"node3": newFakeManager(testPartitionTable()),
+	}
+	managers["node2"].activateErr = map[uint32]error{0: nvlinkErr}
+	fleet, _ := newTestFleet([]string{"node1", "node2", "node3", "node4"}, managers)
+
+	report, err := fleet.GangActivate(8)
+	if !errors.Is(err, nvlinkErr) {
+		t.Errorf("Expected the NVLink error, got %v", err)
+	}
+	if report.Committed {
+		t.Error("Expected an uncommitted report")
+	}
+
+	statuses := []StepStatus{}
+	for _, host := range report.Hosts {
+		statuses = append(statuses, host.Status)
+	}
+	expected := []StepStatus{StepRolledBack, StepFailed, StepRolledBack, StepFailed}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected statuses %v, got %v", expected, statuses)
+	}
+
+	for _, host := range []string{"node1", "node3"} {
+		calls := managers[host].calls
+		if !reflect.DeepEqual(calls, []string{"activate 0", "deactivate 0"}) {
+			t.Errorf("Expected activate then deactivate on %s, got %v", host, calls)
+		}
+	}
+}
+
+func TestFleetGangActivateRollbackFailure(t *testing.T) {
+	managers := map[string]*fakeManager{
+		"node1": newFakeManager(testPartitionTable()),
+		"node2": newFakeManager(testPartitionTable(0)),
+	}
+	fleet, _ := newTestFleet([]string{"node1", "node2"}, managers)
+
+	// node1 cannot be reached again for the rollback
+	connect := fleet.Connect
+	connections := 0
+	fleet.Connect = func(host string) (HostConnection, error) {
+		if host == "node1" {
+			connections++
+			if connections > 1 {
+				return nil, &FMError{Code: FM_ST_CONNECTION_NOT_VALID, Message: "Connection not valid"}
+			}
+		}
+		return connect(host)
+	}
+
+	report, err := fleet.GangActivate(8)
+	if !errors.Is(err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected ErrNoPartitionAvailable, got %v", err)
+	}
+	if report.Hosts[0].Status != StepRollbackFailed || report.Hosts[0].Error == "" {
+		t.Errorf("Expected a failed rollback on node1, got %+v", report.Hosts[0])
+	}
+	if report.Hosts[1].Status != StepFailed {
+		t.Errorf("Expected node2 to fail, got %+v", report.Hosts[1])
+	}
+}
+
+func TestFleetGangActivateTimeout(t *testing.T) {
+	managers := map[string]*fakeManager{
+		"fast": newFakeManager(testPartitionTable()),
+		"slow": newFakeManager(testPartitionTable()),
+	}
+	fleet, _ := newTestFleet([]string{"fast", "slow"}, managers)
+	fleet.Timeout = 50 * time.Millisecond
+	disconnected := &atomic.Int32{}
+	fleet.Connect = func(host string) (HostConnection, error) {
+		conn := fakeHost{managers[host], disconnected}
+		if host == "slow" {
+			return slowHost{conn, 200 * time.Millisecond}, nil
+		}
+		return conn, nil
+	}
+
+	report, err := fleet.GangActivate(8)
+	if !errors.Is(err, ErrHostTimeout) {
+		t.Errorf("Expected ErrHostTimeout, got %v", err)
+	}
+
+	// The slow host activated its partition after timing out and is rolled back
+	for i, host := range []string{"fast", "slow"} {
+		if report.Hosts[i].Status != StepRolledBack {
+			t.Errorf("Expected %s to be rolled back, got %+v", host, report.Hosts[i])
+		}
+		if active := NewConflictGraph(managers[host].partitions).ActiveIDs(); len(active) != 0 {
+			t.Errorf("Expected no active partition on %s, got %v", host, active)
+		}
+	}
+	if report.Hosts[1].Error == "" {
+		t.Errorf("Expected the timeout of the slow host in its report, got %+v", report.Hosts[1])
+	}
+}
+
+func TestFleetGangActivateInvalid(t *testing.T) {
+	fleet, _ := newTestFleet([]string{"node1"}, map[string]*fakeManager{})
+	if _, err := fleet.GangActivate(0); err == nil {
+		t.Error("Expected an error for 0 GPUs")
+	}
+
+	fleet.Hosts = nil
+	if _, err := fleet.GangActivate(8); err == nil {
+		t.Error("Expected an error without hosts")
+	}
+}
@@ -0,0 +1,97 @@
+package fabricmanager
+
+// PartitionNode is a partition within the containment hierarchy. The children
+// of a node are the largest partitions whose GPUs are a strict subset of the
+// node's GPUs.
+type PartitionNode struct {
+	Partition Partition        `json:"partition" yaml:"partition"`
+	State     PartitionState   `json:"state" yaml:"state"`
+	BlockedBy []uint32         `json:"blockedBy" yaml:"blockedBy"`
+	Children  []*PartitionNode `json:"children" yaml:"children"`
+}
+
+// Walk calls fn for the node and all its descendants, depth first. The depth
+// of the node itself is 0.
+func (n *PartitionNode) Walk(fn func(node *PartitionNode, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *PartitionNode) walk(fn func(node *PartitionNode, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// gpuSet returns the set of GPU physical IDs used by a partition
+func gpuSet(partition Partition) map[uint32]struct{} {
+	set := make(map[uint32]struct{}, len(partition.GPUs))
+	for _, gpu := range partition.GPUs {
+		set[gpu.PhysicalID] = struct{}{}
+	}
+	return set
+}
+
+// containsStrictly reports whether outer holds every GPU of inner and more
+func containsStrictly(outer, inner map[uint32]struct{}) bool {
+	if len(inner) >= len(outer) {
+		return false
+	}
+	for id := range inner {
+		if _, ok := outer[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildPartitionTree computes the containment hierarchy of a partition table
+// from GPU membership and returns its roots, sorted by ID. Each partition is
+// attached to the smallest partition strictly containing it. The state of
+// every node is derived from the active state reported in the table.
+func BuildPartitionTree(partitions []Partition) []*PartitionNode {
+	g := NewConflictGraph(partitions)
+	active := g.ActiveIDs()
+
+	sorted := g.Partitions()
+	nodes := make([]*PartitionNode, len(sorted))
+	sets := make([]map[uint32]struct{}, len(sorted))
+	for i, partition := range sorted {
+		nodes[i] = &PartitionNode{
+			Partition: partition,
+			State:     g.State(partition.ID, active),
+			BlockedBy: g.BlockedBy(partition.ID, active),
+		}
+		sets[i] = gpuSet(partition)
+	}
+
+	var roots []*PartitionNode
+	for i := range nodes {
+		parent := -1
+		for j := range nodes {
+			if i == j || !containsStrictly(sets[j], sets[i]) {
+				continue
+			}
+			if parent < 0 || len(sets[j]) < len(sets[parent]) {
+				parent = j
+			}
+		}
+		if parent < 0 {
+			roots = append(roots, nodes[i])
+		} else {
+			nodes[parent].Children = append(nodes[parent].Children, nodes[i])
+		}
+	}
+
+	// Nodes were visited in ID order, so roots and children are already sorted
+	return roots
+}
+
+// GetPartitionTree gets the containment hierarchy of the supported fabric partitions
+func (c *Client) GetPartitionTree() ([]*PartitionNode, error) {
+	partitions, err := c.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	return BuildPartitionTree(partitions), nil
+}
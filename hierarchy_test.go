@@ -0,0 +1,61 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildPartitionTree(t *testing.T) {
+	roots := BuildPartitionTree(testPartitionTable(3))
+
+	if len(roots) != 1 || roots[0].Partition.ID != 0 {
+		t.Fatalf("Expected the 8-GPU partition to be the only root, got %d roots", len(roots))
+	}
+
+	var children []uint32
+	for _, child := range roots[0].Children {
+		children = append(children, child.Partition.ID)
+	}
+	if expected := []uint32{1, 2}; !reflect.DeepEqual(children, expected) {
+		t.Errorf("Expected children %v of partition 0, got %v", expected, children)
+	}
+
+	// Every partition appears exactly once, 1-GPU partitions at depth 3
+	seen := make(map[uint32]int)
+	roots[0].Walk(func(node *PartitionNode, depth int) {
+		seen[node.Partition.ID]++
+		if node.Partition.NumGPUs == 1 && depth != 3 {
+			t.Errorf("Expected 1-GPU partition %d at depth 3, got %d", node.Partition.ID, depth)
+		}
+	})
+	if len(seen) != 15 {
+		t.Errorf("Expected 15 partitions in the tree, got %d", len(seen))
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("Expected partition %d once in the tree, got %d", id, count)
+		}
+	}
+}
+
+func TestPartitionTreeStates(t *testing.T) {
+	roots := BuildPartitionTree(testPartitionTable(3))
+
+	states := make(map[uint32]*PartitionNode)
+	roots[0].Walk(func(node *PartitionNode, depth int) {
+		states[node.Partition.ID] = node
+	})
+
+	if states[3].State != PartitionStateActive {
+		t.Errorf("Expected partition 3 to be active, got %s", states[3].State)
+	}
+	if states[0].State != PartitionStateBlocked || !reflect.DeepEqual(states[0].BlockedBy, []uint32{3}) {
+		t.Errorf("Expected partition 0 to be blocked by 3, got %s %v", states[0].State, states[0].BlockedBy)
+	}
+	if states[7].State != PartitionStateBlocked {
+		t.Errorf("Expected partition 7 inside the active partition to be blocked, got %s", states[7].State)
+	}
+	if states[2].State != PartitionStateAvailable {
+		t.Errorf("Expected partition 2 to be available, got %s", states[2].State)
+	}
+}
@@ -0,0 +1,119 @@
+package fabricmanager
+
+// FailedGPUImpact lists the partitions using a GPU reported with failed NVLinks
+type FailedGPUImpact struct {
+	UUID        string   `json:"uuid" yaml:"uuid"`
+	PCIBusID    string   `json:"pciBusId" yaml:"pciBusId"`
+	FailedPorts []uint32 `json:"failedPorts" yaml:"failedPorts"`
+	// Found is false when the GPU does not appear in the partition table
+	Found            bool     `json:"found" yaml:"found"`
+	PhysicalID       uint32   `json:"physicalId" yaml:"physicalId"`
+	Partitions       []uint32 `json:"partitions" yaml:"partitions"`
+	ActivePartitions []uint32 `json:"activePartitions" yaml:"activePartitions"`
+}
+
+// AffectedPartition is a partition using GPUs with failed or missing NVLinks
+type AffectedPartition struct {
+	ID       uint32 `json:"id" yaml:"id"`
+	IsActive bool   `json:"isActive" yaml:"isActive"`
+	// FailedGPUs are the physical IDs of GPUs reported by GetNvlinkFailedDevices
+	FailedGPUs []uint32 `json:"failedGPUs" yaml:"failedGPUs"`
+	// DegradedGPUs are the physical IDs of GPUs with fewer NVLinks available than their maximum
+	DegradedGPUs []uint32 `json:"degradedGPUs" yaml:"degradedGPUs"`
+}
+
+// NvlinkImpactReport maps NVLink failures to the partitions they affect
+type NvlinkImpactReport struct {
+	FailedGPUs         []FailedGPUImpact        `json:"failedGPUs" yaml:"failedGPUs"`
+	FailedSwitches     []NvlinkFailedDeviceInfo `json:"failedSwitches" yaml:"failedSwitches"`
+	AffectedPartitions []AffectedPartition      `json:"affectedPartitions" yaml:"affectedPartitions"`
+	// DegradedWorkloads are the IDs of the active affected partitions
+	DegradedWorkloads []uint32 `json:"degradedWorkloads" yaml:"degradedWorkloads"`
+}
+
+// AnalyzeNvlinkImpact maps the GPUs reported with failed NVLinks to the
+// supported partitions including them, matching by UUID and PCI bus ID. It also
+// flags partitions with a GPU whose NumNvLinksAvailable is below MaxNumNvLinks.
+func AnalyzeNvlinkImpact(partitions []Partition, failed *NvlinkFailedDevices) *NvlinkImpactReport {
+	report := &NvlinkImpactReport{
+		FailedGPUs:         []FailedGPUImpact{},
+		FailedSwitches:     []NvlinkFailedDeviceInfo{},
+		AffectedPartitions: []AffectedPartition{},
+		DegradedWorkloads:  []uint32{},
+	}
+	if failed != nil {
+		report.FailedSwitches = append(report.FailedSwitches, failed.SwitchInfo...)
+	}
+
+	sorted := NewConflictGraph(partitions).Partitions()
+
+	// Map each failed GPU to the partitions using it
+	if failed != nil {
+		for _, device := range failed.GPUInfo {
+			impact := FailedGPUImpact{
+				UUID:             device.UUID,
+				PCIBusID:         device.PCIBusID,
+				FailedPorts:      device.PortNums,
+				Partitions:       []uint32{},
+				ActivePartitions: []uint32{},
+			}
+			single := newFailedGPUSet(&NvlinkFailedDevices{GPUInfo: []NvlinkFailedDeviceInfo{device}})
+			for _, partition := range sorted {
+				for _, gpu := range partition.GPUs {
+					if _, ok := single.lookup(gpu); !ok {
+						continue
+					}
+					impact.Found = true
+					impact.PhysicalID = gpu.PhysicalID
+					impact.Partitions = append(impact.Partitions, partition.ID)
+					if partition.IsActive {
+						impact.ActivePartitions = append(impact.ActivePartitions, partition.ID)
+					}
+					break
+				}
+			}
+			report.FailedGPUs = append(report.FailedGPUs, impact)
+		}
+	}
+
+	// Flag every partition with failed or degraded GPUs
+	failedGPUs := newFailedGPUSet(failed)
+	for _, partition := range sorted {
+		affected := AffectedPartition{
+			ID:           partition.ID,
+			IsActive:     partition.IsActive,
+			FailedGPUs:   []uint32{},
+			DegradedGPUs: []uint32{},
+		}
+		for _, gpu := range partition.GPUs {
+			if _, ok := failedGPUs.lookup(gpu); ok {
+				affected.FailedGPUs = append(affected.FailedGPUs, gpu.PhysicalID)
+			}
+			if gpu.NumNvLinksAvailable < gpu.MaxNumNvLinks {
+				affected.DegradedGPUs = append(affected.DegradedGPUs, gpu.PhysicalID)
+			}
+		}
+		if len(affected.FailedGPUs) == 0 && len(affected.DegradedGPUs) == 0 {
+			continue
+		}
+		report.AffectedPartitions = append(report.AffectedPartitions, affected)
+		if partition.IsActive {
+			report.DegradedWorkloads = append(report.DegradedWorkloads, partition.ID)
+		}
+	}
+
+	return report
+}
+
+// GetNvlinkImpact analyzes which partitions are affected by NVLink failures
+func (c *Client) GetNvlinkImpact() (*NvlinkImpactReport, error) {
+	partitions, err := c.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	failed, err := c.GetNvlinkFailedDevices()
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeNvlinkImpact(partitions, failed), nil
+}
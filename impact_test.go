@@ -0,0 +1,63 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeNvlinkImpact(t *testing.T) {
+	partitions := testPartitionTable(5)
+	// GPU 6 lost two links
+	for i := range partitions {
+		for j := range partitions[i].GPUs {
+			if partitions[i].GPUs[j].PhysicalID == 6 {
+				partitions[i].GPUs[j].NumNvLinksAvailable = 16
+			}
+		}
+	}
+
+	failed := &NvlinkFailedDevices{
+		NumGPUs: 2,
+		GPUInfo: []NvlinkFailedDeviceInfo{
+			{UUID: "GPU-00000005", NumPorts: 1, PortNums: []uint32{4}},
+			{UUID: "GPU-unknown", PCIBusID: "0000:99:00.0", NumPorts: 1, PortNums: []uint32{1}},
+		},
+	}
+
+	report := AnalyzeNvlinkImpact(partitions, failed)
+
+	if len(report.FailedGPUs) != 2 {
+		t.Fatalf("Expected 2 failed GPUs, got %d", len(report.FailedGPUs))
+	}
+	gpu := report.FailedGPUs[0]
+	if !gpu.Found || gpu.PhysicalID != 5 {
+		t.Errorf("Expected failed GPU to be physical ID 5, got found=%t id=%d", gpu.Found, gpu.PhysicalID)
+	}
+	if expected := []uint32{0, 2, 5, 12}; !reflect.DeepEqual(gpu.Partitions, expected) {
+		t.Errorf("Expected partitions %v for GPU 5, got %v", expected, gpu.Partitions)
+	}
+	if expected := []uint32{5}; !reflect.DeepEqual(gpu.ActivePartitions, expected) {
+		t.Errorf("Expected active partitions %v for GPU 5, got %v", expected, gpu.ActivePartitions)
+	}
+	if report.FailedGPUs[1].Found {
+		t.Error("Expected unknown GPU not to be found in the partition table")
+	}
+
+	var affected []uint32
+	for _, partition := range report.AffectedPartitions {
+		affected = append(affected, partition.ID)
+	}
+	if expected := []uint32{0, 2, 5, 6, 12, 13}; !reflect.DeepEqual(affected, expected) {
+		t.Errorf("Expected affected partitions %v, got %v", expected, affected)
+	}
+	if expected := []uint32{5}; !reflect.DeepEqual(report.DegradedWorkloads, expected) {
+		t.Errorf("Expected degraded workloads %v, got %v", expected, report.DegradedWorkloads)
+	}
+}
+
+func TestAnalyzeNvlinkImpactHealthy(t *testing.T) {
+	report := AnalyzeNvlinkImpact(testPartitionTable(0), &NvlinkFailedDevices{})
+	if len(report.FailedGPUs) != 0 || len(report.AffectedPartitions) != 0 || len(report.DegradedWorkloads) != 0 {
+		t.Errorf("Expected no impact on a healthy fabric, got %+v", report)
+	}
+}
@@ -0,0 +1,251 @@
+package fabricmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrLeaseNotFound is returned when no lease exists for a partition
+	ErrLeaseNotFound = errors.New("no lease for partition")
+	// ErrLeaseOwner is returned when a lease is renewed or released by another owner
+	ErrLeaseOwner = errors.New("lease is held by another owner")
+	// ErrLeaseExpired is returned when renewing an expired lease
+	ErrLeaseExpired = errors.New("lease has expired")
+)
+
+// Lease grants an owner the use of an active partition until it expires
+type Lease struct {
+	PartitionID uint32    `json:"partitionId" yaml:"partitionId"`
+	Owner       string    `json:"owner" yaml:"owner"`
+	GPUUUIDs    []string  `json:"gpuUuids" yaml:"gpuUuids"`
+	AcquiredAt  time.Time `json:"acquiredAt" yaml:"acquiredAt"`
+	ExpiresAt   time.Time `json:"expiresAt" yaml:"expiresAt"`
+}
+
+// Expired reports whether the lease has expired at the given time
+func (l Lease) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+// LeaseStore keeps partition leases in a JSON file. Updates take an
+// exclusive advisory lock on a companion ".lock" file and replace the file
+// atomically, so several processes can use the same store.
+type LeaseStore struct {
+	path string
+	// OnReapError, if set, is called when Acquire fails to reap an expired
+	// lease, which is kept for a later attempt
+	OnReapError func(err error)
+}
+
+// NewLeaseStore creates a store backed by the given file
+func NewLeaseStore(path string) *LeaseStore {
+	return &LeaseStore{path: path}
+}
+
+// Path returns the file backing the store
+func (s *LeaseStore) Path() string {
+	return s.path
+}
+
+func (s *LeaseStore) lockPath() string {
+	return s.path + ".lock"
+}
+
+// read loads the leases; a missing file holds no lease
+func (s *LeaseStore) read() ([]Lease, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Lease{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var leases []Lease
+	if err := json.Unmarshal(data, &leases); err != nil {
+		return nil, fmt.Errorf("invalid lease file %s: %v", s.path, err)
+	}
+	return leases, nil
+}
+
+func (s *LeaseStore) write(leases []Lease) error {
+	sort.Slice(leases, func(i, j int) bool { return leases[i].PartitionID < leases[j].PartitionID })
+	data, err := json.MarshalIndent(leases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, append(data, '\n'), 0o644)
+}
+
+// update runs fn on the leases under an exclusive lock and saves the result
+func (s *LeaseStore) update(fn func(leases []Lease) ([]Lease, error)) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return withFileLock(s.lockPath(), true, func() error {
+		leases, err := s.read()
+		if err != nil {
+			return err
+		}
+		updated, err := fn(leases)
+		if updated != nil {
+			if writeErr := s.write(updated); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}
+		return err
+	})
+}
+
+// List returns all leases, expired or not, sorted by partition ID
+func (s *LeaseStore) List() ([]Lease, error) {
+	if _, err := os.Stat(s.path); errors.Is(err, os.ErrNotExist) {
+		return []Lease{}, nil
+	}
+
+	var leases []Lease
+	err := withFileLock(s.lockPath(), false, func() error {
+		var err error
+		leases, err = s.read()
+		return err
+	})
+	return leases, err
+}
+
+// reap deactivates the partitions of expired leases and drops them. Leases
+// whose partition cannot be deactivated are kept and reported in the error.
+func reap(pm PartitionManager, leases []Lease, now time.Time) (kept, reaped []Lease, err error) {
+	kept = []Lease{}
+	for _, lease := range leases {
+		if !lease.Expired(now) {
+			kept = append(kept, lease)
+			continue
+		}
+		if deactivateErr := deactivateLeased(pm, lease.PartitionID); deactivateErr != nil {
+			kept = append(kept, lease)
+			if err == nil {
+				err = fmt.Errorf("failed to deactivate expired lease of partition %d: %w", lease.PartitionID, deactivateErr)
+			}
+			continue
+		}
+		reaped = append(reaped, lease)
+	}
+	return kept, reaped, err
+}
+
+// deactivateLeased deactivates a leased partition, ignoring partitions that are already inactive
+func deactivateLeased(pm PartitionManager, id uint32) error {
+	err := pm.DeactivatePartition(id)
+	var fmErr *FMError
+	if errors.As(err, &fmErr) && (fmErr.Code == FM_ST_PARTITION_ID_NOT_IN_USE || fmErr.Code == FM_ST_RESOURCE_NOT_IN_USE) {
+		return nil
+	}
+	return err
+}
+
+// Reap deactivates the partitions of expired leases and removes the leases
+func (s *LeaseStore) Reap(pm PartitionManager) ([]Lease, error) {
+	var reaped []Lease
+	err := s.update(func(leases []Lease) ([]Lease, error) {
+		var kept []Lease
+		var err error
+		kept, reaped, err = reap(pm, leases, time.Now())
+		return kept, err
+	})
+	return reaped, err
+}
+
+// Acquire reaps expired leases, then selects and activates a partition of
+// numGPUs GPUs with Allocate and leases it to owner for ttl. Failing to reap
+// an expired lease does not prevent the allocation, see OnReapError.
+func (s *LeaseStore) Acquire(pm PartitionManager, numGPUs int, ttl time.Duration, owner string) (*Lease, error) {
+	if owner == "" {
+		return nil, fmt.Errorf("lease owner is required")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid lease duration: %s", ttl)
+	}
+
+	var lease *Lease
+	err := s.update(func(leases []Lease) ([]Lease, error) {
+		now := time.Now()
+		kept, _, err := reap(pm, leases, now)
+		if err != nil && s.OnReapError != nil {
+			s.OnReapError(err)
+		}
+
+		allocation, err := Allocate(pm, numGPUs)
+		if err != nil {
+			return kept, err
+		}
+
+		lease = &Lease{
+			PartitionID: allocation.PartitionID,
+			Owner:       owner,
+			GPUUUIDs:    allocation.GPUUUIDs,
+			AcquiredAt:  now.UTC(),
+			ExpiresAt:   now.Add(ttl).UTC(),
+		}
+		return append(kept, *lease), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Renew extends the lease of a partition held by owner to ttl from now
+func (s *LeaseStore) Renew(id uint32, owner string, ttl time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid lease duration: %s", ttl)
+	}
+
+	var lease *Lease
+	err := s.update(func(leases []Lease) ([]Lease, error) {
+		now := time.Now()
+		for i := range leases {
+			if leases[i].PartitionID != id {
+				continue
+			}
+			if leases[i].Owner != owner {
+				return nil, fmt.Errorf("%w: partition %d is leased by %s", ErrLeaseOwner, id, leases[i].Owner)
+			}
+			if leases[i].Expired(now) {
+				return nil, fmt.Errorf("%w: partition %d", ErrLeaseExpired, id)
+			}
+			leases[i].ExpiresAt = now.Add(ttl).UTC()
+			lease = &leases[i]
+			return leases, nil
+		}
+		return nil, fmt.Errorf("%w %d", ErrLeaseNotFound, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Release deactivates a partition leased by owner and removes the lease
+func (s *LeaseStore) Release(pm PartitionManager, id uint32, owner string) error {
+	return s.update(func(leases []Lease) ([]Lease, error) {
+		for i, lease := range leases {
+			if lease.PartitionID != id {
+				continue
+			}
+			if lease.Owner != owner {
+				return nil, fmt.Errorf("%w: partition %d is leased by %s", ErrLeaseOwner, id, lease.Owner)
+			}
+			if err := deactivateLeased(pm, id); err != nil {
+				return nil, err
+			}
+			return append(leases[:i], leases[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w %d", ErrLeaseNotFound, id)
+	})
+}
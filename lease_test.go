@@ -0,0 +1,181 @@
+package fabricmanager
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLeaseLifecycle(t *testing.T) {
+	store := NewLeaseStore(filepath.Join(t.TempDir(), "leases.json"))
+	fm := newFakeManager(testPartitionTable())
+
+	lease, err := store.Acquire(fm, 4, time.Hour, "job123")
+	if err != nil {
+		t.Fatalf("Failed to acquire lease: %v", err)
+	}
+	if lease.PartitionID != 1 || lease.Owner != "job123" {
+		t.Errorf("Expected partition 1 leased by job123, got %+v", lease)
+	}
+
+	if _, err := store.Renew(lease.PartitionID, "someone-else", time.Hour); !errors.Is(err, ErrLeaseOwner) {
+		t.Errorf("Expected ErrLeaseOwner, got %v", err)
+	}
+	renewed, err := store.Renew(lease.PartitionID, "job123", 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to renew lease: %v", err)
+	}
+	if !renewed.ExpiresAt.After(lease.ExpiresAt) {
+		t.Errorf("Expected renewed lease to expire after %s, got %s", lease.ExpiresAt, renewed.ExpiresAt)
+	}
+
+	if err := store.Release(fm, lease.PartitionID, "job123"); err != nil {
+		t.Fatalf("Failed to release lease: %v", err)
+	}
+	if leases, _ := store.List(); len(leases) != 0 {
+		t.Errorf("Expected no lease left, got %+v", leases)
+	}
+	if active := NewConflictGraph(fm.partitions).ActiveIDs(); len(active) != 0 {
+		t.Errorf("Expected released partition to be deactivated, got %v", active)
+	}
+	if err := store.Release(fm, lease.PartitionID, "job123"); !errors.Is(err, ErrLeaseNotFound) {
+		t.Errorf("Expected ErrLeaseNotFound, got %v", err)
+	}
+}
+
+func TestLeaseReap(t *testing.T) {
+	store := NewLeaseStore(filepath.Join(t.TempDir(), "leases.json"))
+	fm := newFakeManager(testPartitionTable())
+
+	short, err := store.Acquire(fm, 4, time.Millisecond, "short")
+	if err != nil {
+		t.Fatalf("Failed to acquire lease: %v", err)
+	}
+	if _, err := store.Acquire(fm, 4, time.Hour, "long"); err != nil {
+		t.Fatalf("Failed to acquire lease: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	reaped, err := store.Reap(fm)
+	if err != nil {
+		t.Fatalf("Failed to reap leases: %v", err)
+	}
+	if len(reaped) != 1 || reaped[0].PartitionID != short.PartitionID {
+		t.Errorf("Expected the short lease to be reaped, got %+v", reaped)
+	}
+	if active := NewConflictGraph(fm.partitions).ActiveIDs(); !reflect.DeepEqual(active, []uint32{2}) {
+		t.Errorf("Expected only the long lease partition to stay active, got %v", active)
+	}
+
+	if _, err := store.Renew(short.PartitionID, "short", time.Hour); !errors.Is(err, ErrLeaseNotFound) {
+		t.Errorf("Expected reaped lease to be gone, got %v", err)
+	}
+}
+
+// stuckManager is a fakeManager failing to deactivate partitions
+type stuckManager struct {
+	*fakeManager
+}
+
+func (m stuckManager) DeactivatePartition(id uint32) error {
+	return &FMError{Code: FM_ST_GENERIC_ERROR, Message: "Generic error"}
+}
+
+func TestLeaseAcquireReapFailure(t *testing.T) {
+	store := NewLeaseStore(filepath.Join(t.TempDir(), "leases.json"))
+	fm := newFakeManager(testPartitionTable())
+
+	stuck, err := store.Acquire(fm, 4, time.Millisecond, "stuck")
+	if err != nil {
+		t.Fatalf("Failed to acquire lease: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// The unreapable lease is reported and kept, the allocation goes on
+	var reapErrs []error
+	store.OnReapError = func(err error) { reapErrs = append(reapErrs, err) }
+	lease, err := store.Acquire(stuckManager{fm}, 4, time.Hour, "job123")
+	if err != nil {
+		t.Fatalf("Failed to acquire lease despite the unreapable one: %v", err)
+	}
+	if lease.PartitionID == stuck.PartitionID {
+		t.Errorf("Expected another partition than the unreapable %d", stuck.PartitionID)
+	}
+	if len(reapErrs) != 1 {
+		t.Errorf("Expected one reap failure to be reported, got %v", reapErrs)
+	}
+	if leases, _ := store.List(); len(leases) != 2 {
+		t.Errorf("Expected the unreapable lease to be kept, got %+v", leases)
+	}
+
+	// An expired lease can still be released by its owner
+	if err := store.Release(fm, stuck.PartitionID, "stuck"); err != nil {
+		t.Errorf("Failed to release expired lease: %v", err)
+	}
+}
+
+func TestLeaseConcurrentAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases.json")
+	fm := newFakeManager(testPartitionTable())
+	var fmMu sync.Mutex
+
+	// Each goroutine uses its own store, as separate processes would
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = NewLeaseStore(path).Acquire(lockedManager{fm, &fmMu}, 1, time.Hour, "worker")
+		}()
+	}
+	wg.Wait()
+
+	leases, err := NewLeaseStore(path).List()
+	if err != nil {
+		t.Fatalf("Failed to list leases: %v", err)
+	}
+	if len(leases) != 8 {
+		t.Errorf("Expected 8 leases, got %d", len(leases))
+	}
+}
+
+// lockedManager serializes calls to a fakeManager
+type lockedManager struct {
+	fm *fakeManager
+	mu *sync.Mutex
+}
+
+func (l lockedManager) GetSupportedPartitions() ([]Partition, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fm.GetSupportedPartitions()
+}
+
+func (l lockedManager) ActivatePartition(id uint32) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fm.ActivatePartition(id)
+}
+
+func (l lockedManager) DeactivatePartition(id uint32) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fm.DeactivatePartition(id)
+}
+
+func (l lockedManager) GetNvlinkFailedDevices() (*NvlinkFailedDevices, error) {
+	return l.fm.GetNvlinkFailedDevices()
+}
+
+func (l lockedManager) GetUnsupportedPartitions() ([]UnsupportedPartition, error) {
+	return l.fm.GetUnsupportedPartitions()
+}
+
+func (l lockedManager) SetActivatedPartitions(ids []uint32) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fm.SetActivatedPartitions(ids)
+}
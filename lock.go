@@ -0,0 +1,205 @@
+package fabricmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultHostLockPath is the lock file serializing mutating operations on a host
+const DefaultHostLockPath = "/run/fmpm/fmpm.lock"
+
+// hostLockPollInterval is how often a busy host lock is retried
+const hostLockPollInterval = 100 * time.Millisecond
+
+// ErrHostLockTimeout is returned when the host lock could not be acquired in time
+var ErrHostLockTimeout = errors.New("timed out waiting for host lock")
+
+// flock applies an advisory lock operation (syscall.LOCK_SH, LOCK_EX or LOCK_UN) to f
+func flock(f *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// withFileLock runs fn while holding an advisory lock on path, creating the
+// file if needed. Shared locks allow concurrent readers.
+func withFileLock(path string, exclusive bool, fn func() error) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := flock(f, how); err != nil {
+		return err
+	}
+	defer flock(f, syscall.LOCK_UN)
+
+	return fn()
+}
+
+// HostLockHolder describes the process holding the host lock
+type HostLockHolder struct {
+	PID        int       `json:"pid" yaml:"pid"`
+	Command    string    `json:"command" yaml:"command"`
+	AcquiredAt time.Time `json:"acquiredAt" yaml:"acquiredAt"`
+}
+
+// Alive reports whether the holder process is still running
+func (h HostLockHolder) Alive() bool {
+	if h.PID <= 0 {
+		return false
+	}
+	err := syscall.Kill(h.PID, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func (h HostLockHolder) String() string {
+	return fmt.Sprintf("PID %d (%s) since %s", h.PID, h.Command, h.AcquiredAt.Local().Format(time.RFC3339))
+}
+
+// HostLockTimeoutError is returned when another process keeps the host lock
+// for longer than the wait timeout
+type HostLockTimeoutError struct {
+	Path string
+	// Holder is nil when the lock file does not record its holder
+	Holder *HostLockHolder
+	// Stale is true when the recorded holder is no longer running, which
+	// means the lock is held by a process that inherited its file descriptor
+	Stale bool
+}
+
+func (e *HostLockTimeoutError) Error() string {
+	msg := fmt.Sprintf("%v %s", ErrHostLockTimeout, e.Path)
+	if e.Holder != nil {
+		msg += " held by " + e.Holder.String()
+	}
+	if e.Stale {
+		msg += " (stale: holder is no longer running)"
+	}
+	return msg
+}
+
+func (e *HostLockTimeoutError) Unwrap() error {
+	return ErrHostLockTimeout
+}
+
+// HostLock is an advisory lock serializing mutating operations between
+// processes on the same host. The lock is released by the kernel when its
+// holder exits, and the lock file records the PID of the current holder.
+type HostLock struct {
+	Path string
+	// Timeout is how long to wait for another holder, 0 fails immediately
+	Timeout time.Duration
+	// OnWait, if set, is called once when the lock is held by another process
+	OnWait func(holder *HostLockHolder)
+	// OnStale, if set, is called when the lock file records a holder that is
+	// no longer running
+	OnStale func(holder HostLockHolder)
+}
+
+// NewHostLock creates a host lock on path, or DefaultHostLockPath if empty
+func NewHostLock(path string, timeout time.Duration) *HostLock {
+	if path == "" {
+		path = DefaultHostLockPath
+	}
+	return &HostLock{Path: path, Timeout: timeout}
+}
+
+// readHolder returns the holder recorded in the lock file, or nil if there is none
+func readHolder(f *os.File) *HostLockHolder {
+	data := make([]byte, 4096)
+	n, err := f.ReadAt(data, 0)
+	if n == 0 || (err != nil && n == len(data)) {
+		return nil
+	}
+	var holder HostLockHolder
+	if err := json.Unmarshal(data[:n], &holder); err != nil || holder.PID == 0 {
+		return nil
+	}
+	return &holder
+}
+
+// writeHolder records the current process as the holder of the lock
+func writeHolder(f *os.File) error {
+	data, err := json.Marshal(HostLockHolder{
+		PID:        os.Getpid(),
+		Command:    strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " "),
+		AcquiredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// Do runs fn while holding the host lock
+func (l *HostLock) Do(fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create host lock directory: %v", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open host lock: %v", err)
+	}
+	defer f.Close()
+
+	deadline := time.Now().Add(l.Timeout)
+	waiting := false
+	for {
+		err := flock(f, syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("failed to lock %s: %v", l.Path, err)
+		}
+
+		holder := readHolder(f)
+		if !waiting {
+			waiting = true
+			if l.OnWait != nil {
+				l.OnWait(holder)
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return &HostLockTimeoutError{Path: l.Path, Holder: holder, Stale: holder != nil && !holder.Alive()}
+		}
+		time.Sleep(min(hostLockPollInterval, time.Until(deadline)))
+	}
+	defer flock(f, syscall.LOCK_UN)
+
+	// A holder left in the file was not cleared on release, so it exited abnormally
+	if holder := readHolder(f); holder != nil && holder.PID != os.Getpid() && l.OnStale != nil {
+		l.OnStale(*holder)
+	}
+	if err := writeHolder(f); err != nil {
+		return fmt.Errorf("failed to record host lock holder: %v", err)
+	}
+	defer f.Truncate(0)
+
+	return fn()
+}
+
+// WithHostLock runs fn while holding the host lock at path, waiting up to
+// timeout for other processes to release it
+func WithHostLock(path string, timeout time.Duration, fn func() error) error {
+	return NewHostLock(path, timeout).Do(fn)
+}
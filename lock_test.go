@@ -0,0 +1,85 @@
+package fabricmanager
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWithHostLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fmpm.lock")
+
+	ran := false
+	if err := WithHostLock(path, time.Second, func() error {
+		ran = true
+		return nil
+	}); err != nil || !ran {
+		t.Fatalf("Expected the function to run under the lock, got ran=%v err=%v", ran, err)
+	}
+
+	expected := errors.New("boom")
+	if err := WithHostLock(path, time.Second, func() error { return expected }); err != expected {
+		t.Errorf("Expected the function error to be returned, got %v", err)
+	}
+
+	// The holder is cleared on release
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("Expected an empty lock file after release, got %q", data)
+	}
+}
+
+func TestHostLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fmpm.lock")
+
+	err := WithHostLock(path, time.Second, func() error {
+		var waitedFor *HostLockHolder
+		lock := NewHostLock(path, 200*time.Millisecond)
+		lock.OnWait = func(holder *HostLockHolder) { waitedFor = holder }
+
+		err := lock.Do(func() error {
+			t.Error("Expected the nested lock not to be acquired")
+			return nil
+		})
+		if waitedFor == nil || waitedFor.PID != os.Getpid() {
+			t.Errorf("Expected OnWait with the current PID as holder, got %+v", waitedFor)
+		}
+		return err
+	})
+
+	var timeoutErr *HostLockTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrHostLockTimeout) {
+		t.Fatalf("Expected HostLockTimeoutError, got %v", err)
+	}
+	if timeoutErr.Holder == nil || timeoutErr.Holder.PID != os.Getpid() || timeoutErr.Stale {
+		t.Errorf("Expected a live holder with the current PID, got %+v", timeoutErr)
+	}
+}
+
+func TestHostLockStaleHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fmpm.lock")
+
+	// Record a holder that exited without releasing the lock
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Cannot run a child process: %v", err)
+	}
+	pid := cmd.Process.Pid
+	record := `{"pid":` + strconv.Itoa(pid) + `,"command":"fmpm activate 1","acquiredAt":"2026-01-01T00:00:00Z"}`
+	if err := os.WriteFile(path, []byte(record), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stale *HostLockHolder
+	lock := NewHostLock(path, time.Second)
+	lock.OnStale = func(holder HostLockHolder) { stale = &holder }
+	if err := lock.Do(func() error { return nil }); err != nil {
+		t.Fatalf("Expected the lock to be acquired, got %v", err)
+	}
+	if stale == nil || stale.PID != pid || stale.Alive() {
+		t.Errorf("Expected a stale holder with PID %d, got %+v", pid, stale)
+	}
+}
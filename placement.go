@@ -0,0 +1,110 @@
+package fabricmanager
+
+import (
+	"fmt"
+	"sort"
+)
+
+// HostFit describes the partition a host would activate for a request and
+// how well it fits
+type HostFit struct {
+	Host        string   `json:"host" yaml:"host"`
+	PartitionID uint32   `json:"partitionId" yaml:"partitionId"`
+	GPUUUIDs    []string `json:"gpuUuids" yaml:"gpuUuids"`
+	FreeGPUs    int      `json:"freeGPUs" yaml:"freeGPUs"`
+	// Activatable is how many partitions of the requested size the host can activate together
+	Activatable int `json:"activatable" yaml:"activatable"`
+	// FragmentationAfter is the fragmentation score of the host once the partition is active
+	FragmentationAfter float64 `json:"fragmentationAfter" yaml:"fragmentationAfter"`
+	// DegradationPercent is the unavailable NVLink bandwidth of the partition
+	DegradationPercent float64 `json:"degradationPercent" yaml:"degradationPercent"`
+}
+
+// EvaluateHostFit finds the partition of numGPUs GPUs SelectPartition would
+// activate on a host, skipping GPUs with failed NVLinks, and scores it. It
+// returns ErrNoPartitionAvailable if the host has no such partition free.
+func EvaluateHostFit(host string, partitions []Partition, failed *NvlinkFailedDevices, numGPUs int) (*HostFit, error) {
+	if numGPUs <= 0 {
+		return nil, fmt.Errorf("invalid number of GPUs: %d", numGPUs)
+	}
+
+	partition, err := SelectPartition(partitions, failed, numGPUs)
+	if err != nil {
+		return nil, err
+	}
+	before := NewCapacityReport(partitions)
+
+	// Score the table as it would be with the partition active
+	after := make([]Partition, len(partitions))
+	copy(after, partitions)
+	for i := range after {
+		if after[i].ID == partition.ID {
+			after[i].IsActive = true
+		}
+	}
+
+	fit := &HostFit{
+		Host:               host,
+		PartitionID:        partition.ID,
+		GPUUUIDs:           []string{},
+		FreeGPUs:           before.FreeGPUs,
+		Activatable:        before.Activatable(numGPUs),
+		FragmentationAfter: NewCapacityReport(after).FragmentationScore,
+		DegradationPercent: partition.DegradationPercent(),
+	}
+	for _, gpu := range partition.GPUs {
+		fit.GPUUUIDs = append(fit.GPUUUIDs, gpu.UUID)
+	}
+	return fit, nil
+}
+
+// RankHostFits sorts fits from the best to the worst: the least fragmentation
+// left after activation first, then the healthiest NVLinks. Remaining ties go
+// to the host with the fewest free GPUs, keeping emptier hosts for larger
+// requests, then to the first host name.
+func RankHostFits(fits []HostFit) {
+	sort.SliceStable(fits, func(i, j int) bool {
+		a, b := fits[i], fits[j]
+		if a.FragmentationAfter != b.FragmentationAfter {
+			return a.FragmentationAfter < b.FragmentationAfter
+		}
+		if a.DegradationPercent != b.DegradationPercent {
+			return a.DegradationPercent < b.DegradationPercent
+		}
+		if a.FreeGPUs != b.FreeGPUs {
+			return a.FreeGPUs < b.FreeGPUs
+		}
+		return a.Host < b.Host
+	})
+}
+
+// FindHosts evaluates every host for a partition of numGPUs GPUs and returns
+// the hosts that can activate one right now, best fit first. The host results
+// hold the fit of every host, or its error: ErrNoPartitionAvailable for hosts
+// without a free partition of that size.
+func (f *Fleet) FindHosts(numGPUs int) ([]HostFit, []HostResult) {
+	results := f.Run(func(host string, pm PartitionManager) (any, error) {
+		partitions, err := pm.GetSupportedPartitions()
+		if err != nil {
+			return nil, err
+		}
+		failed, err := pm.GetNvlinkFailedDevices()
+		if err != nil {
+			return nil, err
+		}
+		fit, err := EvaluateHostFit(host, partitions, failed, numGPUs)
+		if err != nil {
+			return nil, err
+		}
+		return fit, nil
+	})
+
+	fits := []HostFit{}
+	for _, result := range results {
+		if result.Err == nil {
+			fits = append(fits, *result.Value.(*HostFit))
+		}
+	}
+	RankHostFits(fits)
+	return fits, results
+}
@@ -0,0 +1,110 @@
+package fabricmanager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// degradeGPU removes NVLinks from a GPU in every partition of the table
+func degradeGPU(partitions []Partition, physicalID uint32, links uint32) []Partition {
+	for i := range partitions {
+		for j := range partitions[i].GPUs {
+			if partitions[i].GPUs[j].PhysicalID == physicalID {
+				partitions[i].GPUs[j].NumNvLinksAvailable = links
+			}
+		}
+	}
+	return partitions
+}
+
+func TestEvaluateHostFit(t *testing.T) {
+	fit, err := EvaluateHostFit("node1", testPartitionTable(), nil, 8)
+	if err != nil {
+		t.Fatalf("Failed to evaluate fit: %v", err)
+	}
+	expected := &HostFit{
+		Host:        "node1",
+		PartitionID: 0,
+		GPUUUIDs: []string{"GPU-00000000", "GPU-00000001", "GPU-00000002", "GPU-00000003",
+			"GPU-00000004", "GPU-00000005", "GPU-00000006", "GPU-00000007"},
+		FreeGPUs:    8,
+		Activatable: 1,
+	}
+	if !reflect.DeepEqual(fit, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, fit)
+	}
+
+	// With GPU 0 busy, the 4-GPU partition 2 leaves GPUs 1-3, of which at most 2 fit together
+	fit, err = EvaluateHostFit("node2", testPartitionTable(7), nil, 4)
+	if err != nil {
+		t.Fatalf("Failed to evaluate fit: %v", err)
+	}
+	if fit.PartitionID != 2 || fit.FreeGPUs != 7 || fit.Activatable != 1 {
+		t.Errorf("Expected partition 2 with 7 free GPUs and 1 activatable, got %+v", fit)
+	}
+	if fit.FragmentationAfter < 0.33 || fit.FragmentationAfter > 0.34 {
+		t.Errorf("Expected fragmentation 1/3 after activation, got %f", fit.FragmentationAfter)
+	}
+}
+
+func TestEvaluateHostFitUnavailable(t *testing.T) {
+	if _, err := EvaluateHostFit("node1", testPartitionTable(1), nil, 8); !errors.Is(err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected ErrNoPartitionAvailable with partition 1 active, got %v", err)
+	}
+
+	failed := &NvlinkFailedDevices{GPUInfo: []NvlinkFailedDeviceInfo{{UUID: "GPU-00000003"}}}
+	if _, err := EvaluateHostFit("node1", testPartitionTable(), failed, 8); !errors.Is(err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected ErrNoPartitionAvailable with a failed GPU, got %v", err)
+	}
+
+	if _, err := EvaluateHostFit("node1", testPartitionTable(), nil, 0); err == nil {
+		t.Error("Expected an error for 0 GPUs")
+	}
+}
+
+func TestRankHostFits(t *testing.T) {
+	fits := []HostFit{}
+	for host, partitions := range map[string][]Partition{
+		"fragmented": testPartitionTable(7),
+		"degraded":   degradeGPU(testPartitionTable(), 2, 12),
+		"b-clean":    testPartitionTable(),
+		"a-clean":    testPartitionTable(),
+	} {
+		fit, err := EvaluateHostFit(host, partitions, nil, 4)
+		if err != nil {
+			t.Fatalf("Failed to evaluate %s: %v", host, err)
+		}
+		fits = append(fits, *fit)
+	}
+
+	RankHostFits(fits)
+	hosts := []string{}
+	for _, fit := range fits {
+		hosts = append(hosts, fit.Host)
+	}
+	expected := []string{"a-clean", "b-clean", "degraded", "fragmented"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected ranking %v, got %v", expected, hosts)
+	}
+}
+
+func TestFleetFindHosts(t *testing.T) {
+	fleet, _ := newTestFleet([]string{"busy", "down", "free", "half"}, map[string]*fakeManager{
+		"busy": newFakeManager(testPartitionTable(0)),
+		"free": newFakeManager(testPartitionTable()),
+		"half": newFakeManager(testPartitionTable(1)),
+	})
+
+	fits, results := fleet.FindHosts(4)
+	// Both leave no fragmentation, half is preferred to keep free whole
+	if len(fits) != 2 || fits[0].Host != "half" || fits[1].Host != "free" {
+		t.Errorf("Expected half then free, got %+v", fits)
+	}
+	if !errors.Is(results[0].Err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected ErrNoPartitionAvailable for busy, got %v", results[0].Err)
+	}
+	if results[1].Err == nil || errors.Is(results[1].Err, ErrNoPartitionAvailable) {
+		t.Errorf("Expected a connection error for down, got %v", results[1].Err)
+	}
+}
@@ -60,6 +60,6 @@ echo "  export LD_LIBRARY_PATH=\"$PWD/$LIBDIR:\$LD_LIBRARY_PATH\""
 echo "  export CGO_LDFLAGS=\"-L$PWD/$LIBDIR\""
 echo ""
 echo "Example build:"
-echo "  CGO_LDFLAGS=\"-L$PWD/$LIBDIR\" LD_LIBRARY_PATH=\"$PWD/$LIBDIR\" CGO_ENABLED=1 go build -o fmpm cmd/fmpm/main.go"
+echo "  CGO_LDFLAGS=\"-L$PWD/$LIBDIR\" LD_LIBRARY_PATH=\"$PWD/$LIBDIR\" CGO_ENABLED=1 go build -o fmpm ./cmd/fmpm"
 echo ""
 echo "Done." 
\ No newline at end of file
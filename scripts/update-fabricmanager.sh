@@ -270,7 +270,7 @@ run_tests() {
 build_after_update() {
     log_info "Building after update..."
     
-    if CGO_ENABLED=1 go build -o fmpm cmd/fmpm/main.go; then
+    if CGO_ENABLED=1 go build -o fmpm ./cmd/fmpm; then
         log_success "Build successful"
     else
         log_error "Build failed"
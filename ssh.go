@@ -0,0 +1,332 @@
+package fabricmanager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultSSHPort is the port of SSH servers given without one
+const DefaultSSHPort = 22
+
+// SSHConfig describes how to reach a FabricManager through SSH
+type SSHConfig struct {
+	// Host is the SSH server running FabricManager, as [user@]host[:port]
+	Host string
+	// JumpHosts are the SSH servers to go through to reach Host, in order,
+	// as [user@]host[:port]
+	JumpHosts []string
+	// User is the user for servers given without one, the current user if empty
+	User string
+	// IdentityFiles are private keys tried after those of the SSH agent. The
+	// default keys of ~/.ssh are tried if empty.
+	IdentityFiles []string
+	// KnownHostsFiles verify the host keys of the servers, ~/.ssh/known_hosts if empty
+	KnownHostsFiles []string
+	// Auth replaces the SSH agent and identity files if set
+	Auth []ssh.AuthMethod
+	// HostKeyCallback replaces the known hosts files if set
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout bounds the connection to each SSH server, 0 for no limit
+	Timeout time.Duration
+}
+
+// defaultIdentityFiles are the keys of ~/.ssh tried when no identity file is given
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// ParseSSHTarget splits [user@]host[:port] into the user, defaultUser if
+// none is given, and the host:port address of the SSH server
+func ParseSSHTarget(target, defaultUser string) (string, string, error) {
+	login, host := defaultUser, target
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		login, host = target[:i], target[i+1:]
+	}
+	if login == "" || host == "" {
+		return "", "", fmt.Errorf("invalid SSH target %q: must be [user@]host[:port]", target)
+	}
+	return login, HostAddress(host, DefaultSSHPort), nil
+}
+
+// isUnixSocketAddress reports whether a FabricManager address is a UNIX socket path
+func isUnixSocketAddress(address string) bool {
+	return strings.HasPrefix(address, "/") || strings.Contains(address, ".sock")
+}
+
+// signers returns the keys of the SSH agent, if running, and of the identity
+// files. Missing or encrypted default keys are skipped.
+func (c SSHConfig) signers(agentClient agent.Agent) ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	if agentClient != nil {
+		if keys, err := agentClient.Signers(); err == nil {
+			signers = append(signers, keys...)
+		}
+	}
+
+	files, explicit := c.IdentityFiles, len(c.IdentityFiles) > 0
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return signers, nil
+		}
+		for _, name := range defaultIdentityFiles {
+			files = append(files, filepath.Join(home, ".ssh", name))
+		}
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if explicit {
+				return nil, fmt.Errorf("failed to read SSH key: %w", err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			var passphraseErr *ssh.PassphraseMissingError
+			if explicit && errors.As(err, &passphraseErr) {
+				return nil, fmt.Errorf("SSH key %s is encrypted, add it to the SSH agent instead", file)
+			}
+			if explicit {
+				return nil, fmt.Errorf("invalid SSH key %s: %w", file, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// clientConfig returns the authentication methods and host key callback of the servers
+func (c SSHConfig) clientConfig(agentClient agent.Agent) ([]ssh.AuthMethod, ssh.HostKeyCallback, error) {
+	auth := c.Auth
+	if auth == nil {
+		signers, err := c.signers(agentClient)
+		if err != nil {
+			return nil, nil, err
+		}
+		// A single public key method, as a failed method is not tried twice
+		auth = []ssh.AuthMethod{ssh.PublicKeys(signers...)}
+	}
+
+	hostKeyCallback := c.HostKeyCallback
+	if hostKeyCallback == nil {
+		files := c.KnownHostsFiles
+		if len(files) == 0 {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to find the known hosts file: %w", err)
+			}
+			files = []string{filepath.Join(home, ".ssh", "known_hosts")}
+		}
+		callback, err := knownhosts.New(files...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read known hosts: %w", err)
+		}
+		hostKeyCallback = callback
+	}
+	return auth, hostKeyCallback, nil
+}
+
+// SSHTunnel forwards a local UNIX socket to a FabricManager address on the
+// other side of one or more SSH servers. The socket is created in a directory
+// only accessible to the current user.
+type SSHTunnel struct {
+	clients  []*ssh.Client
+	listener net.Listener
+	dir      string
+	network  string
+	address  string
+	wg       sync.WaitGroup
+	close    sync.Once
+	closeErr error
+}
+
+// OpenSSHTunnel connects to the SSH server of config, through its jump hosts,
+// and forwards a local socket to address, a host:port or UNIX socket path as
+// seen from the server. The address is checked to be reachable.
+func OpenSSHTunnel(config SSHConfig, address string) (*SSHTunnel, error) {
+	defaultUser := config.User
+	if defaultUser == "" {
+		if current, err := user.Current(); err == nil {
+			defaultUser = current.Username
+		}
+	}
+
+	var agentClient agent.Agent
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" && config.Auth == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			defer conn.Close()
+			agentClient = agent.NewClient(conn)
+		}
+	}
+	auth, hostKeyCallback, err := config.clientConfig(agentClient)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel := &SSHTunnel{network: "tcp", address: address}
+	if isUnixSocketAddress(address) {
+		tunnel.network = "unix"
+	}
+
+	hops := append(append([]string{}, config.JumpHosts...), config.Host)
+	for _, hop := range hops {
+		client, err := tunnel.dial(hop, defaultUser, &ssh.ClientConfig{
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         config.Timeout,
+		})
+		if err != nil {
+			tunnel.Close()
+			return nil, fmt.Errorf("failed to connect to SSH host %s: %w", hop, err)
+		}
+		tunnel.clients = append(tunnel.clients, client)
+	}
+
+	// Fail now rather than on the first connection through the tunnel
+	conn, err := tunnel.remote().Dial(tunnel.network, address)
+	if err != nil {
+		tunnel.Close()
+		return nil, fmt.Errorf("failed to reach %s through SSH host %s: %w", address, config.Host, err)
+	}
+	conn.Close()
+
+	if tunnel.dir, err = os.MkdirTemp("", "fmpm-ssh-"); err != nil {
+		tunnel.Close()
+		return nil, fmt.Errorf("failed to create the tunnel socket: %w", err)
+	}
+	if tunnel.listener, err = net.Listen("unix", filepath.Join(tunnel.dir, "fm.sock")); err != nil {
+		tunnel.Close()
+		return nil, fmt.Errorf("failed to create the tunnel socket: %w", err)
+	}
+
+	tunnel.wg.Add(1)
+	go tunnel.serve()
+	return tunnel, nil
+}
+
+// dial connects to an SSH server, through the previous servers of the tunnel if any
+func (t *SSHTunnel) dial(target, defaultUser string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	login, addr, err := ParseSSHTarget(target, defaultUser)
+	if err != nil {
+		return nil, err
+	}
+	config.User = login
+
+	var conn net.Conn
+	if len(t.clients) == 0 {
+		conn, err = net.DialTimeout("tcp", addr, config.Timeout)
+	} else {
+		conn, err = t.remote().Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Connections through another server do not support deadlines
+	if config.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+// remote returns the client of the last SSH server reached
+func (t *SSHTunnel) remote() *ssh.Client {
+	return t.clients[len(t.clients)-1]
+}
+
+// LocalAddress returns the path of the local socket forwarded to FabricManager
+func (t *SSHTunnel) LocalAddress() string {
+	return t.listener.Addr().String()
+}
+
+func (t *SSHTunnel) serve() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.forward(local)
+	}
+}
+
+// forward copies data between a local connection and FabricManager until
+// either side closes
+func (t *SSHTunnel) forward(local net.Conn) {
+	defer t.wg.Done()
+	defer local.Close()
+
+	remote, err := t.remote().Dial(t.network, t.address)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Close stops forwarding, closes the SSH connections and removes the local socket
+func (t *SSHTunnel) Close() error {
+	t.close.Do(func() {
+		if t.listener != nil {
+			t.listener.Close()
+		}
+		for i := len(t.clients) - 1; i >= 0; i-- {
+			if err := t.clients[i].Close(); err != nil && t.closeErr == nil {
+				t.closeErr = err
+			}
+		}
+		t.wg.Wait()
+		if t.dir != "" {
+			os.RemoveAll(t.dir)
+		}
+	})
+	return t.closeErr
+}
+
+// ConnectSSH connects to a FabricManager through an SSH tunnel. address is
+// the FabricManager host:port or UNIX socket path as seen from the SSH
+// server. Disconnecting the client closes the tunnel.
+func ConnectSSH(config SSHConfig, address string, timeoutMs int) (*Client, error) {
+	tunnel, err := OpenSSHTunnel(config, address)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := Connect(tunnel.LocalAddress(), timeoutMs)
+	if err != nil {
+		tunnel.Close()
+		return nil, err
+	}
+	client.address = fmt.Sprintf("%s via ssh %s", address, config.Host)
+	client.tunnel = tunnel
+	return client, nil
+}
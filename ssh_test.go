@@ -0,0 +1,298 @@
+package fabricmanager
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newTestSigner generates an SSH key
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer
+}
+
+// testSSHServer is an in-process SSH server accepting one client key and
+// forwarding direct-tcpip and direct-streamlocal channels
+type testSSHServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	server := &testSSHServer{hostKey: newTestSigner(t)}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "admin" && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(server.hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server.addr = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSH(conn, config)
+		}
+	}()
+	return server
+}
+
+func serveTestSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		var network, address string
+		switch newChannel.ChannelType() {
+		case "direct-tcpip":
+			var payload struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			network, address = "tcp", net.JoinHostPort(payload.Host, strconv.FormatUint(uint64(payload.Port), 10))
+		case "direct-streamlocal@openssh.com":
+			var payload struct {
+				Path      string
+				Reserved0 string
+				Reserved1 uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			network, address = "unix", payload.Path
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		target, err := net.Dial(network, address)
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			defer channel.Close()
+			defer target.Close()
+			go io.Copy(target, channel)
+			io.Copy(channel, target)
+		}()
+	}
+}
+
+// newEchoServer listens on network and echoes back everything it receives
+func newEchoServer(t *testing.T, network, address string) string {
+	t.Helper()
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// writeKnownHosts writes a known hosts file trusting the given servers
+func writeKnownHosts(t *testing.T, servers ...*testSSHServer) string {
+	t.Helper()
+	var lines []string
+	for _, server := range servers {
+		lines = append(lines, knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey.PublicKey()))
+	}
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write known hosts: %v", err)
+	}
+	return path
+}
+
+// checkEcho sends a line through the tunnel and expects it back
+func checkEcho(t *testing.T, tunnel *SSHTunnel) {
+	t.Helper()
+	conn, err := net.DialTimeout("unix", tunnel.LocalAddress(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect to the tunnel: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("Failed to write through the tunnel: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("Expected ping back, got %q, %v", line, err)
+	}
+}
+
+func TestParseSSHTarget(t *testing.T) {
+	tests := []struct {
+		target, user, addr string
+	}{
+		{"node1", "root", "node1:22"},
+		{"admin@node1", "admin", "node1:22"},
+		{"admin@node1:2222", "admin", "node1:2222"},
+		{"admin@[fe80::1]:2222", "admin", "[fe80::1]:2222"},
+		{"fe80::1", "root", "[fe80::1]:22"},
+	}
+	for _, test := range tests {
+		user, addr, err := ParseSSHTarget(test.target, "root")
+		if err != nil || user != test.user || addr != test.addr {
+			t.Errorf("ParseSSHTarget(%q) = %q, %q, %v, expected %q, %q", test.target, user, addr, err, test.user, test.addr)
+		}
+	}
+
+	for _, target := range []string{"", "admin@", "@node1"} {
+		if _, _, err := ParseSSHTarget(target, "root"); err == nil {
+			t.Errorf("Expected an error for %q", target)
+		}
+	}
+}
+
+func TestSSHTunnel(t *testing.T) {
+	signer := newTestSigner(t)
+	server := newTestSSHServer(t, signer.PublicKey())
+	fm := newEchoServer(t, "tcp", "127.0.0.1:0")
+
+	tunnel, err := OpenSSHTunnel(SSHConfig{
+		Host:            "admin@" + server.addr,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		KnownHostsFiles: []string{writeKnownHosts(t, server)},
+		Timeout:         5 * time.Second,
+	}, fm)
+	if err != nil {
+		t.Fatalf("Failed to open tunnel: %v", err)
+	}
+	checkEcho(t, tunnel)
+	checkEcho(t, tunnel)
+
+	socket := tunnel.LocalAddress()
+	if err := tunnel.Close(); err != nil {
+		t.Errorf("Failed to close tunnel: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(socket)); !os.IsNotExist(err) {
+		t.Errorf("Expected the tunnel socket directory to be removed, got %v", err)
+	}
+}
+
+func TestSSHTunnelJumpHostToUnixSocket(t *testing.T) {
+	signer := newTestSigner(t)
+	jump := newTestSSHServer(t, signer.PublicKey())
+	server := newTestSSHServer(t, signer.PublicKey())
+	fm := newEchoServer(t, "unix", filepath.Join(t.TempDir(), "fm.sock"))
+
+	tunnel, err := OpenSSHTunnel(SSHConfig{
+		Host:            server.addr,
+		JumpHosts:       []string{jump.addr},
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		KnownHostsFiles: []string{writeKnownHosts(t, jump, server)},
+	}, fm)
+	if err != nil {
+		t.Fatalf("Failed to open tunnel: %v", err)
+	}
+	defer tunnel.Close()
+	checkEcho(t, tunnel)
+}
+
+func TestSSHTunnelErrors(t *testing.T) {
+	signer := newTestSigner(t)
+	server := newTestSSHServer(t, signer.PublicKey())
+	other := newTestSSHServer(t, signer.PublicKey())
+	fm := newEchoServer(t, "tcp", "127.0.0.1:0")
+
+	config := SSHConfig{
+		Host:            "admin@" + server.addr,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		KnownHostsFiles: []string{writeKnownHosts(t, server)},
+	}
+
+	// Host key of another server
+	unknown := config
+	unknown.KnownHostsFiles = []string{writeKnownHosts(t, other)}
+	if _, err := OpenSSHTunnel(unknown, fm); err == nil {
+		t.Error("Expected an error for an unknown host key")
+	}
+
+	// Key not authorized
+	unauthorized := config
+	unauthorized.Auth = []ssh.AuthMethod{ssh.PublicKeys(newTestSigner(t))}
+	if _, err := OpenSSHTunnel(unauthorized, fm); err == nil {
+		t.Error("Expected an error for an unauthorized key")
+	}
+
+	// FabricManager not listening
+	if _, err := OpenSSHTunnel(config, filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Error("Expected an error for an unreachable FabricManager")
+	}
+
+	// Missing identity file
+	missingKey := config
+	missingKey.Auth = nil
+	missingKey.IdentityFiles = []string{filepath.Join(t.TempDir(), "id_missing")}
+	if _, err := OpenSSHTunnel(missingKey, fm); err == nil {
+		t.Error("Expected an error for a missing identity file")
+	}
+}
@@ -0,0 +1,198 @@
+package fabricmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SavedStateVersion is the format version of saved state files
+const SavedStateVersion = 1
+
+// ErrFingerprintMismatch is returned when restoring a saved state onto a partition table with a different layout
+var ErrFingerprintMismatch = errors.New("partition table fingerprint does not match the saved state")
+
+// PartitionTableFingerprint returns a digest of the layout of a partition
+// table: the ID, size and GPU physical IDs of every partition. UUIDs, PCI bus
+// IDs, NVLink counts and active state are ignored, so nodes of the same
+// platform share a fingerprint.
+func PartitionTableFingerprint(partitions []Partition) string {
+	h := sha256.New()
+	for _, partition := range NewConflictGraph(partitions).Partitions() {
+		fmt.Fprintf(h, "%d:%d:%s\n", partition.ID, len(partition.GPUs), formatIDs(sortedIDs(gpuSet(partition))))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// SavedState records the active partitions of a node so they can be handed to
+// a restarted FabricManager running in resiliency mode
+type SavedState struct {
+	Version          int       `json:"version" yaml:"version"`
+	Hostname         string    `json:"hostname" yaml:"hostname"`
+	SavedAt          time.Time `json:"savedAt" yaml:"savedAt"`
+	LibraryVersion   string    `json:"libraryVersion" yaml:"libraryVersion"`
+	Fingerprint      string    `json:"fingerprint" yaml:"fingerprint"`
+	NumPartitions    int       `json:"numPartitions" yaml:"numPartitions"`
+	ActivePartitions []uint32  `json:"activePartitions" yaml:"activePartitions"`
+}
+
+// NewSavedState captures the active partitions of a partition table
+func NewSavedState(partitions []Partition) *SavedState {
+	hostname, _ := os.Hostname()
+	active := NewConflictGraph(partitions).ActiveIDs()
+	if active == nil {
+		active = []uint32{}
+	}
+	return &SavedState{
+		Version:          SavedStateVersion,
+		Hostname:         hostname,
+		SavedAt:          time.Now().UTC(),
+		LibraryVersion:   Version,
+		Fingerprint:      PartitionTableFingerprint(partitions),
+		NumPartitions:    len(partitions),
+		ActivePartitions: active,
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so readers see either the old or the new content
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Save writes the state to path atomically
+func (s *SavedState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o644)
+}
+
+// LoadSavedState reads a state file written by SavedState.Save
+func LoadSavedState(path string) (*SavedState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var state SavedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+	if state.Version != SavedStateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d", state.Version)
+	}
+	return &state, nil
+}
+
+// Check verifies that the saved state was taken on a partition table with the same layout
+func (s *SavedState) Check(partitions []Partition) error {
+	if fingerprint := PartitionTableFingerprint(partitions); fingerprint != s.Fingerprint {
+		return fmt.Errorf("%w: saved %s, current %s", ErrFingerprintMismatch, s.Fingerprint, fingerprint)
+	}
+	return nil
+}
+
+// RestoreState checks the saved state against the current partition table and
+// hands its active partitions to FabricManager with SetActivatedPartitions.
+// FabricManager refuses to report the partition table while it waits for the
+// activated partition list in resiliency mode; the list is then set first and
+// the partition table checked afterwards.
+func RestoreState(pm PartitionManager, state *SavedState) error {
+	partitions, err := pm.GetSupportedPartitions()
+	if err == nil {
+		if err := state.Check(partitions); err != nil {
+			return err
+		}
+		return pm.SetActivatedPartitions(state.ActivePartitions)
+	}
+	if !IsAwaitingActivatedList(err) {
+		return err
+	}
+
+	if err := pm.SetActivatedPartitions(state.ActivePartitions); err != nil {
+		return err
+	}
+	partitions, err = pm.GetSupportedPartitions()
+	if err != nil {
+		return fmt.Errorf("activated partitions set, failed to check the partition table: %v", err)
+	}
+	if err := state.Check(partitions); err != nil {
+		return fmt.Errorf("activated partitions set, but %w", err)
+	}
+	return nil
+}
+
+// IsAwaitingActivatedList reports whether an error means FabricManager
+// restarted in resiliency mode and waits for SetActivatedPartitions before
+// serving partition queries
+func IsAwaitingActivatedList(err error) bool {
+	var fmErr *FMError
+	return errors.As(err, &fmErr) && fmErr.Code == FM_ST_NOT_READY
+}
+
+// IsNotReadyError reports whether an error means FabricManager is not ready
+// yet, for example while it is starting or restarting
+func IsNotReadyError(err error) bool {
+	var fmErr *FMError
+	if !errors.As(err, &fmErr) {
+		return false
+	}
+	return fmErr.Code == FM_ST_NOT_READY ||
+		fmErr.Code == FM_ST_RESOURCE_NOT_READY ||
+		IsConnectionError(fmErr)
+}
+
+// WaitForReady calls probe every interval until it succeeds, fails with an
+// error other than a not-ready or connection error, or ctx is done
+func WaitForReady(ctx context.Context, interval time.Duration, probe func() error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := probe()
+		if err == nil || !IsNotReadyError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("FabricManager not ready: %w (last error: %v)", ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package fabricmanager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPartitionTableFingerprint(t *testing.T) {
+	base := PartitionTableFingerprint(testPartitionTable())
+
+	// Active state, UUIDs and order do not matter
+	other := testPartitionTable(1, 6)
+	other[0].GPUs[0].UUID = "GPU-other"
+	other[0], other[3] = other[3], other[0]
+	if fingerprint := PartitionTableFingerprint(other); fingerprint != base {
+		t.Errorf("Expected fingerprint %s, got %s", base, fingerprint)
+	}
+
+	// Membership does
+	changed := testPartitionTable()
+	changed[3].GPUs[1].PhysicalID = 2
+	if PartitionTableFingerprint(changed) == base {
+		t.Error("Expected a different fingerprint for a different layout")
+	}
+}
+
+func TestSavedStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "fmpm.json")
+
+	state := NewSavedState(testPartitionTable(1, 5))
+	if err := state.Save(path); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	loaded, err := LoadSavedState(path)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.ActivePartitions, []uint32{1, 5}) || loaded.Fingerprint != state.Fingerprint {
+		t.Errorf("Expected loaded state to match saved state, got %+v", loaded)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary file left behind, got %d entries", len(entries))
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	state := NewSavedState(testPartitionTable(1, 5))
+
+	fm := newFakeManager(testPartitionTable())
+	if err := RestoreState(fm, state); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fm.activated, []uint32{1, 5}) {
+		t.Errorf("Expected activated list [1 5], got %v", fm.activated)
+	}
+
+	changed := testPartitionTable()
+	changed = changed[:len(changed)-1]
+	fm = newFakeManager(changed)
+	if err := RestoreState(fm, state); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("Expected ErrFingerprintMismatch, got %v", err)
+	}
+	if len(fm.calls) != 0 {
+		t.Errorf("Expected no call on fingerprint mismatch, got %v", fm.calls)
+	}
+}
+
+// awaitingManager is a fakeManager restarted in resiliency mode, refusing
+// partition queries until the activated partition list is set
+type awaitingManager struct {
+	*fakeManager
+	awaiting bool
+}
+
+func (m *awaitingManager) GetSupportedPartitions() ([]Partition, error) {
+	if m.awaiting {
+		return nil, &FMError{Code: FM_ST_NOT_READY, Message: "Not ready"}
+	}
+	return m.fakeManager.GetSupportedPartitions()
+}
+
+func (m *awaitingManager) SetActivatedPartitions(ids []uint32) error {
+	m.awaiting = false
+	for _, id := range ids {
+		m.setActive(id, true)
+	}
+	return m.fakeManager.SetActivatedPartitions(ids)
+}
+
+func TestRestoreStateAwaitingActivatedList(t *testing.T) {
+	state := NewSavedState(testPartitionTable(1, 5))
+
+	fm := &awaitingManager{fakeManager: newFakeManager(testPartitionTable()), awaiting: true}
+	if err := RestoreState(fm, state); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fm.activated, []uint32{1, 5}) {
+		t.Errorf("Expected activated list [1 5], got %v", fm.activated)
+	}
+
+	// The layout can only be checked once the list is set
+	changed := testPartitionTable()
+	fm = &awaitingManager{fakeManager: newFakeManager(changed[:len(changed)-1]), awaiting: true}
+	if err := RestoreState(fm, state); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("Expected ErrFingerprintMismatch, got %v", err)
+	}
+	if !reflect.DeepEqual(fm.calls, []string{"set-activated"}) {
+		t.Errorf("Expected the activated list to be set once, got %v", fm.calls)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	attempts := 0
+	err := WaitForReady(context.Background(), time.Millisecond, func() error {
+		attempts++
+		if attempts < 3 {
+			return &FMError{Code: FM_ST_NOT_READY, Message: "Not ready"}
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Expected success after 3 attempts, got %v after %d", err, attempts)
+	}
+
+	badParam := &FMError{Code: FM_ST_BADPARAM, Message: "Bad parameter"}
+	if err := WaitForReady(context.Background(), time.Millisecond, func() error { return badParam }); err != badParam {
+		t.Errorf("Expected non-retryable error to be returned, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = WaitForReady(ctx, time.Millisecond, func() error {
+		return &FMError{Code: FM_ST_CONNECTION_NOT_VALID, Message: "Connection not valid"}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
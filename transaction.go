@@ -0,0 +1,215 @@
+package fabricmanager
+
+import (
+	"fmt"
+)
+
+// StepAction is the operation performed by a transaction step
+type StepAction string
+
+const (
+	// ActionActivate activates a partition
+	ActionActivate StepAction = "activate"
+	// ActionDeactivate deactivates a partition
+	ActionDeactivate StepAction = "deactivate"
+)
+
+// StepStatus is the outcome of a transaction step
+type StepStatus string
+
+const (
+	// StepPending means the step was not attempted
+	StepPending StepStatus = "pending"
+	// StepApplied means the step succeeded
+	StepApplied StepStatus = "applied"
+	// StepFailed means the step failed
+	StepFailed StepStatus = "failed"
+	// StepRolledBack means the step succeeded and was undone after a later failure
+	StepRolledBack StepStatus = "rolled-back"
+	// StepRollbackFailed means the step succeeded but could not be undone
+	StepRollbackFailed StepStatus = "rollback-failed"
+)
+
+// TransactionStep records one activation or deactivation of a transaction
+type TransactionStep struct {
+	Action      StepAction `json:"action" yaml:"action"`
+	PartitionID uint32     `json:"partitionId" yaml:"partitionId"`
+	Status      StepStatus `json:"status" yaml:"status"`
+	Error       string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func (s TransactionStep) String() string {
+	if s.Error != "" {
+		return fmt.Sprintf("%s partition %d: %s (%s)", s.Action, s.PartitionID, s.Status, s.Error)
+	}
+	return fmt.Sprintf("%s partition %d: %s", s.Action, s.PartitionID, s.Status)
+}
+
+// TransactionReport describes every step of a transaction
+type TransactionReport struct {
+	Steps     []TransactionStep `json:"steps" yaml:"steps"`
+	Committed bool              `json:"committed" yaml:"committed"`
+}
+
+// Transaction changes the set of active partitions as a unit: deactivations
+// are applied before activations and completed steps are undone if a later
+// step fails
+type Transaction struct {
+	deactivate []uint32
+	activate   []uint32
+}
+
+// NewTransaction creates an empty transaction
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Activate adds partitions to activate
+func (t *Transaction) Activate(ids ...uint32) *Transaction {
+	t.activate = append(t.activate, ids...)
+	return t
+}
+
+// Deactivate adds partitions to deactivate
+func (t *Transaction) Deactivate(ids ...uint32) *Transaction {
+	t.deactivate = append(t.deactivate, ids...)
+	return t
+}
+
+// Steps returns the steps of the transaction in execution order
+func (t *Transaction) Steps() []TransactionStep {
+	steps := make([]TransactionStep, 0, len(t.deactivate)+len(t.activate))
+	for _, id := range t.deactivate {
+		steps = append(steps, TransactionStep{Action: ActionDeactivate, PartitionID: id, Status: StepPending})
+	}
+	for _, id := range t.activate {
+		steps = append(steps, TransactionStep{Action: ActionActivate, PartitionID: id, Status: StepPending})
+	}
+	return steps
+}
+
+// Validate checks the transaction against a partition table: deactivated
+// partitions must be active, activated partitions must exist and be inactive,
+// and the resulting set of active partitions must be conflict-free
+func (t *Transaction) Validate(partitions []Partition) error {
+	g := NewConflictGraph(partitions)
+
+	target := make(map[uint32]bool)
+	for _, id := range g.ActiveIDs() {
+		target[id] = true
+	}
+
+	seen := make(map[uint32]bool)
+	for _, id := range t.deactivate {
+		partition, ok := g.Partition(id)
+		if !ok {
+			return fmt.Errorf("partition %d does not exist", id)
+		}
+		if !partition.IsActive {
+			return fmt.Errorf("partition %d is not active", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("partition %d is listed more than once", id)
+		}
+		seen[id] = true
+		delete(target, id)
+	}
+
+	for _, id := range t.activate {
+		partition, ok := g.Partition(id)
+		if !ok {
+			return fmt.Errorf("partition %d does not exist", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("partition %d is listed more than once", id)
+		}
+		seen[id] = true
+		if partition.IsActive {
+			return fmt.Errorf("partition %d is already active", id)
+		}
+		for other := range target {
+			if g.ConflictsWith(id, other) {
+				return fmt.Errorf("partition %d shares GPUs with partition %d, which would remain active", id, other)
+			}
+		}
+		target[id] = true
+	}
+
+	return nil
+}
+
+// Apply validates the transaction against the current partition table and
+// executes it. If a step fails, the completed steps are undone in reverse order.
+// The report lists every step, whether or not an error is returned.
+func (t *Transaction) Apply(pm PartitionManager) (*TransactionReport, error) {
+	report := &TransactionReport{Steps: t.Steps()}
+
+	partitions, err := pm.GetSupportedPartitions()
+	if err != nil {
+		return report, err
+	}
+	if err := t.Validate(partitions); err != nil {
+		return report, fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	for i := range report.Steps {
+		step := &report.Steps[i]
+		if step.Action == ActionDeactivate {
+			err = pm.DeactivatePartition(step.PartitionID)
+		} else {
+			err = pm.ActivatePartition(step.PartitionID)
+		}
+		if err == nil {
+			step.Status = StepApplied
+			continue
+		}
+
+		step.Status = StepFailed
+		step.Error = err.Error()
+		stepErr := fmt.Errorf("failed to %s partition %d: %w", step.Action, step.PartitionID, err)
+
+		if rollbackErr := rollback(pm, report.Steps[:i]); rollbackErr != nil {
+			return report, fmt.Errorf("%w; rollback incomplete: %v", stepErr, rollbackErr)
+		}
+		return report, stepErr
+	}
+
+	report.Committed = true
+	return report, nil
+}
+
+// rollback undoes the given applied steps in reverse order
+func rollback(pm PartitionManager, steps []TransactionStep) error {
+	var firstErr error
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := &steps[i]
+		var err error
+		if step.Action == ActionDeactivate {
+			err = pm.ActivatePartition(step.PartitionID)
+		} else {
+			err = pm.DeactivatePartition(step.PartitionID)
+		}
+		if err != nil {
+			step.Status = StepRollbackFailed
+			step.Error = err.Error()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to undo %s of partition %d: %w", step.Action, step.PartitionID, err)
+			}
+			continue
+		}
+		step.Status = StepRolledBack
+	}
+	return firstErr
+}
+
+// SwitchPartitions deactivates the from partitions and activates the to
+// partitions in a single transaction
+func SwitchPartitions(pm PartitionManager, from, to []uint32) (*TransactionReport, error) {
+	return NewTransaction().Deactivate(from...).Activate(to...).Apply(pm)
+}
+
+// SwitchPartitions deactivates the from partitions and activates the to
+// partitions, undoing completed steps if one fails
+func (c *Client) SwitchPartitions(from, to []uint32) (*TransactionReport, error) {
+	return SwitchPartitions(c, from, to)
+}
@@ -0,0 +1,74 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransactionValidate(t *testing.T) {
+	partitions := testPartitionTable(0)
+
+	if err := NewTransaction().Deactivate(0).Activate(1, 2).Validate(partitions); err != nil {
+		t.Errorf("Expected switch from 0 to 1,2 to be valid, got %v", err)
+	}
+	if err := NewTransaction().Activate(1).Validate(partitions); err == nil {
+		t.Error("Expected activation conflicting with an active partition to be rejected")
+	}
+	if err := NewTransaction().Deactivate(0).Activate(1, 3).Validate(partitions); err == nil {
+		t.Error("Expected overlapping target partitions to be rejected")
+	}
+	if err := NewTransaction().Deactivate(1).Validate(partitions); err == nil {
+		t.Error("Expected deactivation of an inactive partition to be rejected")
+	}
+	if err := NewTransaction().Deactivate(0).Activate(99).Validate(partitions); err == nil {
+		t.Error("Expected activation of an unknown partition to be rejected")
+	}
+}
+
+func TestTransactionApply(t *testing.T) {
+	fm := newFakeManager(testPartitionTable(0))
+
+	report, err := SwitchPartitions(fm, []uint32{0}, []uint32{1, 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !report.Committed {
+		t.Error("Expected the transaction to be committed")
+	}
+
+	expected := []string{"deactivate 0", "activate 1", "activate 2"}
+	if !reflect.DeepEqual(fm.calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, fm.calls)
+	}
+	if active := NewConflictGraph(fm.partitions).ActiveIDs(); !reflect.DeepEqual(active, []uint32{1, 2}) {
+		t.Errorf("Expected partitions 1 and 2 to be active, got %v", active)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	fm := newFakeManager(testPartitionTable(0))
+	fm.activateErr = map[uint32]error{2: &FMError{Code: FM_ST_NVLINK_ERROR, Message: "NVLink error"}}
+
+	report, err := SwitchPartitions(fm, []uint32{0}, []uint32{1, 2})
+	if err == nil {
+		t.Fatal("Expected the transaction to fail")
+	}
+	if report.Committed {
+		t.Error("Expected the transaction not to be committed")
+	}
+
+	expected := []string{"deactivate 0", "activate 1", "activate 2", "deactivate 1", "activate 0"}
+	if !reflect.DeepEqual(fm.calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, fm.calls)
+	}
+
+	statuses := []StepStatus{StepRolledBack, StepRolledBack, StepFailed}
+	for i, step := range report.Steps {
+		if step.Status != statuses[i] {
+			t.Errorf("Expected step %d to be %s, got %s", i, statuses[i], step.Status)
+		}
+	}
+	if active := NewConflictGraph(fm.partitions).ActiveIDs(); !reflect.DeepEqual(active, []uint32{0}) {
+		t.Errorf("Expected partition 0 to be active again, got %v", active)
+	}
+}
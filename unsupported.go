@@ -0,0 +1,140 @@
+package fabricmanager
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formatIDs formats IDs as a comma-separated list
+func formatIDs(ids []uint32) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(strs, ",")
+}
+
+// UnsupportedExplanation cross-references an unsupported partition with the
+// supported partition table and the NVLink failures
+type UnsupportedExplanation struct {
+	ID             uint32   `json:"id" yaml:"id"`
+	GPUPhysicalIDs []uint32 `json:"gpuPhysicalIds" yaml:"gpuPhysicalIds"`
+	// MissingGPUs are the physical IDs absent from every supported partition
+	MissingGPUs []uint32 `json:"missingGPUs" yaml:"missingGPUs"`
+	// FailedGPUs are the physical IDs reported by GetNvlinkFailedDevices
+	FailedGPUs []uint32 `json:"failedGPUs" yaml:"failedGPUs"`
+	// DegradedGPUs are the physical IDs with fewer NVLinks available than their maximum
+	DegradedGPUs []uint32 `json:"degradedGPUs" yaml:"degradedGPUs"`
+	// Fallbacks are the supported partitions using only GPUs of this
+	// partition and no GPU with failed NVLinks, largest first
+	Fallbacks []uint32 `json:"fallbacks" yaml:"fallbacks"`
+}
+
+// Reasons returns human-readable reasons for the partition being unsupported
+func (e *UnsupportedExplanation) Reasons() []string {
+	var reasons []string
+	if len(e.MissingGPUs) > 0 {
+		reasons = append(reasons, "GPUs missing from the supported partitions: "+formatIDs(e.MissingGPUs))
+	}
+	if len(e.FailedGPUs) > 0 {
+		reasons = append(reasons, "GPUs with failed NVLinks: "+formatIDs(e.FailedGPUs))
+	}
+	if len(e.DegradedGPUs) > 0 {
+		reasons = append(reasons, "GPUs with degraded NVLinks: "+formatIDs(e.DegradedGPUs))
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "no missing or failed GPU found; the GPU combination may not be supported by the platform")
+	}
+	return reasons
+}
+
+// ExplainUnsupportedPartitions explains each unsupported partition: which of
+// its GPUs are missing from the supported partitions, which have failed or
+// degraded NVLinks, and which supported partitions can be used instead.
+// failed may be nil.
+func ExplainUnsupportedPartitions(unsupported []UnsupportedPartition, supported []Partition, failed *NvlinkFailedDevices) []UnsupportedExplanation {
+	// Index the GPUs of the supported table by physical ID
+	known := make(map[uint32]PartitionGPUInfo)
+	sorted := NewConflictGraph(supported).Partitions()
+	for _, partition := range sorted {
+		for _, gpu := range partition.GPUs {
+			if _, ok := known[gpu.PhysicalID]; !ok {
+				known[gpu.PhysicalID] = gpu
+			}
+		}
+	}
+	failedGPUs := newFailedGPUSet(failed)
+
+	explanations := make([]UnsupportedExplanation, 0, len(unsupported))
+	for _, partition := range unsupported {
+		explanation := UnsupportedExplanation{
+			ID:             partition.ID,
+			GPUPhysicalIDs: partition.GPUPhysicalIDs,
+			MissingGPUs:    []uint32{},
+			FailedGPUs:     []uint32{},
+			DegradedGPUs:   []uint32{},
+			Fallbacks:      []uint32{},
+		}
+
+		members := make(map[uint32]struct{}, len(partition.GPUPhysicalIDs))
+		for _, physicalID := range partition.GPUPhysicalIDs {
+			members[physicalID] = struct{}{}
+
+			gpu, ok := known[physicalID]
+			if !ok {
+				explanation.MissingGPUs = append(explanation.MissingGPUs, physicalID)
+				continue
+			}
+			if _, ok := failedGPUs.lookup(gpu); ok {
+				explanation.FailedGPUs = append(explanation.FailedGPUs, physicalID)
+			}
+			if gpu.NumNvLinksAvailable < gpu.MaxNumNvLinks {
+				explanation.DegradedGPUs = append(explanation.DegradedGPUs, physicalID)
+			}
+		}
+
+		// Supported partitions made only of this partition's GPUs
+		var fallbacks []Partition
+		for _, candidate := range sorted {
+			if len(candidate.GPUs) == 0 || failedGPUs.hasFailedGPU(candidate) {
+				continue
+			}
+			inside := true
+			for _, gpu := range candidate.GPUs {
+				if _, ok := members[gpu.PhysicalID]; !ok {
+					inside = false
+					break
+				}
+			}
+			if inside {
+				fallbacks = append(fallbacks, candidate)
+			}
+		}
+		sort.SliceStable(fallbacks, func(i, j int) bool { return len(fallbacks[i].GPUs) > len(fallbacks[j].GPUs) })
+		for _, fallback := range fallbacks {
+			explanation.Fallbacks = append(explanation.Fallbacks, fallback.ID)
+		}
+
+		explanations = append(explanations, explanation)
+	}
+
+	return explanations
+}
+
+// ExplainUnsupportedPartitions gets the unsupported fabric partitions with an explanation for each
+func (c *Client) ExplainUnsupportedPartitions() ([]UnsupportedExplanation, error) {
+	unsupported, err := c.GetUnsupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	supported, err := c.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	failed, err := c.GetNvlinkFailedDevices()
+	if err != nil {
+		return nil, err
+	}
+	return ExplainUnsupportedPartitions(unsupported, supported, failed), nil
+}
@@ -0,0 +1,57 @@
+package fabricmanager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainUnsupportedPartitions(t *testing.T) {
+	// The table lost GPU 3 entirely: only partitions without it remain
+	var supported []Partition
+	for _, partition := range testPartitionTable() {
+		if _, ok := gpuSet(partition)[3]; !ok {
+			supported = append(supported, partition)
+		}
+	}
+
+	unsupported := []UnsupportedPartition{
+		{ID: 1, NumGPUs: 4, GPUPhysicalIDs: []uint32{0, 1, 2, 3}},
+		{ID: 2, NumGPUs: 4, GPUPhysicalIDs: []uint32{4, 5, 6, 7}},
+	}
+	failed := &NvlinkFailedDevices{
+		NumGPUs: 1,
+		GPUInfo: []NvlinkFailedDeviceInfo{{UUID: "GPU-00000001", NumPorts: 2, PortNums: []uint32{0, 1}}},
+	}
+
+	explanations := ExplainUnsupportedPartitions(unsupported, supported, failed)
+	if len(explanations) != 2 {
+		t.Fatalf("Expected 2 explanations, got %d", len(explanations))
+	}
+
+	first := explanations[0]
+	if !reflect.DeepEqual(first.MissingGPUs, []uint32{3}) {
+		t.Errorf("Expected missing GPUs [3], got %v", first.MissingGPUs)
+	}
+	if !reflect.DeepEqual(first.FailedGPUs, []uint32{1}) {
+		t.Errorf("Expected failed GPUs [1], got %v", first.FailedGPUs)
+	}
+	// Partitions 3 and 8 use the failed GPU 1 and are not usable fallbacks
+	if expected := []uint32{7, 9}; !reflect.DeepEqual(first.Fallbacks, expected) {
+		t.Errorf("Expected fallbacks %v, got %v", expected, first.Fallbacks)
+	}
+	if reasons := first.Reasons(); len(reasons) != 2 || !strings.Contains(reasons[0], "missing") {
+		t.Errorf("Expected missing and failed GPU reasons, got %v", reasons)
+	}
+
+	second := explanations[1]
+	if len(second.MissingGPUs) != 0 || len(second.FailedGPUs) != 0 {
+		t.Errorf("Expected no missing or failed GPUs, got %v and %v", second.MissingGPUs, second.FailedGPUs)
+	}
+	if expected := []uint32{2, 5, 6, 11, 12, 13, 14}; !reflect.DeepEqual(second.Fallbacks, expected) {
+		t.Errorf("Expected fallbacks %v, got %v", expected, second.Fallbacks)
+	}
+	if reasons := second.Reasons(); len(reasons) != 1 || !strings.Contains(reasons[0], "platform") {
+		t.Errorf("Expected a generic reason, got %v", reasons)
+	}
+}
@@ -0,0 +1,210 @@
+package fabricmanager
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity of an invariant violation
+type Severity string
+
+const (
+	// SeverityError marks a partition table that cannot be trusted
+	SeverityError Severity = "error"
+	// SeverityWarning marks an unusual but usable partition table
+	SeverityWarning Severity = "warning"
+)
+
+// Names of the checks run by VerifyFabric
+const (
+	CheckPartitionID   = "partition-id"
+	CheckGPUCount      = "gpu-count"
+	CheckGPUIdentity   = "gpu-identity"
+	CheckActiveOverlap = "active-overlap"
+	CheckLineRate      = "line-rate"
+	CheckNvlinkCount   = "nvlink-count"
+)
+
+// Violation is an invariant of the partition table that does not hold
+type Violation struct {
+	Check        string   `json:"check" yaml:"check"`
+	Severity     Severity `json:"severity" yaml:"severity"`
+	PartitionIDs []uint32 `json:"partitionIds" yaml:"partitionIds"`
+	Message      string   `json:"message" yaml:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s: %s", v.Severity, v.Check, v.Message)
+}
+
+// HasErrors reports whether any violation has error severity
+func HasErrors(violations []Violation) bool {
+	for _, violation := range violations {
+		if violation.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyFabric checks a supported partition table against the invariants FabricManager should maintain:
+//   - partition IDs are unique and below FM_MAX_FABRIC_PARTITIONS
+//   - NumGPUs matches the GPU list, which has no duplicates and at most FM_MAX_NUM_GPUS entries
+//   - a physical ID has the same UUID and PCI bus ID in every partition, and a UUID a single physical ID
+//   - active partitions do not share GPUs
+//   - all GPUs report the same NVLink line rate
+//   - no GPU reports more available NVLinks than its maximum
+//
+// Violations are returned errors first, then in check order.
+func VerifyFabric(partitions []Partition) []Violation {
+	var violations []Violation
+	add := func(check string, severity Severity, ids []uint32, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Check:        check,
+			Severity:     severity,
+			PartitionIDs: ids,
+			Message:      fmt.Sprintf(format, args...),
+		})
+	}
+
+	seen := make(map[uint32]bool)
+	for _, partition := range partitions {
+		if seen[partition.ID] {
+			add(CheckPartitionID, SeverityError, []uint32{partition.ID}, "partition %d is listed more than once", partition.ID)
+		}
+		seen[partition.ID] = true
+		if partition.ID >= FM_MAX_FABRIC_PARTITIONS {
+			add(CheckPartitionID, SeverityError, []uint32{partition.ID}, "partition %d is out of range (maximum %d)", partition.ID, FM_MAX_FABRIC_PARTITIONS-1)
+		}
+	}
+	if len(partitions) > FM_MAX_FABRIC_PARTITIONS {
+		add(CheckPartitionID, SeverityError, nil, "%d partitions exceed the maximum of %d", len(partitions), FM_MAX_FABRIC_PARTITIONS)
+	}
+
+	for _, partition := range partitions {
+		if int(partition.NumGPUs) != len(partition.GPUs) {
+			add(CheckGPUCount, SeverityError, []uint32{partition.ID}, "partition %d reports %d GPUs but lists %d", partition.ID, partition.NumGPUs, len(partition.GPUs))
+		}
+		if len(partition.GPUs) > FM_MAX_NUM_GPUS {
+			add(CheckGPUCount, SeverityError, []uint32{partition.ID}, "partition %d lists %d GPUs (maximum %d)", partition.ID, len(partition.GPUs), FM_MAX_NUM_GPUS)
+		}
+		members := make(map[uint32]bool)
+		for _, gpu := range partition.GPUs {
+			if members[gpu.PhysicalID] {
+				add(CheckGPUCount, SeverityError, []uint32{partition.ID}, "partition %d lists GPU %d more than once", partition.ID, gpu.PhysicalID)
+			}
+			members[gpu.PhysicalID] = true
+		}
+	}
+
+	// GPU identity must be consistent across partitions
+	type identity struct {
+		gpu       PartitionGPUInfo
+		partition uint32
+	}
+	byPhysicalID := make(map[uint32]identity)
+	byUUID := make(map[string]identity)
+	for _, partition := range partitions {
+		for _, gpu := range partition.GPUs {
+			if first, ok := byPhysicalID[gpu.PhysicalID]; ok {
+				if first.gpu.UUID != gpu.UUID {
+					add(CheckGPUIdentity, SeverityError, []uint32{first.partition, partition.ID},
+						"GPU %d has UUID %s in partition %d but %s in partition %d",
+						gpu.PhysicalID, first.gpu.UUID, first.partition, gpu.UUID, partition.ID)
+				}
+				if normalizePCIBusID(first.gpu.PCIBusID) != normalizePCIBusID(gpu.PCIBusID) {
+					add(CheckGPUIdentity, SeverityError, []uint32{first.partition, partition.ID},
+						"GPU %d has PCI bus ID %s in partition %d but %s in partition %d",
+						gpu.PhysicalID, first.gpu.PCIBusID, first.partition, gpu.PCIBusID, partition.ID)
+				}
+			} else {
+				byPhysicalID[gpu.PhysicalID] = identity{gpu: gpu, partition: partition.ID}
+			}
+
+			if gpu.UUID == "" {
+				continue
+			}
+			if first, ok := byUUID[gpu.UUID]; ok {
+				if first.gpu.PhysicalID != gpu.PhysicalID {
+					add(CheckGPUIdentity, SeverityError, []uint32{first.partition, partition.ID},
+						"UUID %s is GPU %d in partition %d but GPU %d in partition %d",
+						gpu.UUID, first.gpu.PhysicalID, first.partition, gpu.PhysicalID, partition.ID)
+				}
+			} else {
+				byUUID[gpu.UUID] = identity{gpu: gpu, partition: partition.ID}
+			}
+		}
+	}
+
+	// Active partitions must not share GPUs
+	owner := make(map[uint32]uint32)
+	for _, partition := range partitions {
+		if !partition.IsActive {
+			continue
+		}
+		for _, gpu := range partition.GPUs {
+			if other, ok := owner[gpu.PhysicalID]; ok && other != partition.ID {
+				add(CheckActiveOverlap, SeverityError, []uint32{other, partition.ID},
+					"active partitions %d and %d share GPU %d", other, partition.ID, gpu.PhysicalID)
+				continue
+			}
+			owner[gpu.PhysicalID] = partition.ID
+		}
+	}
+
+	// Line rates should be uniform across the node
+	rates := make(map[uint32]map[uint32]struct{})
+	for _, partition := range partitions {
+		for _, gpu := range partition.GPUs {
+			if rates[gpu.NvlinkLineRateMBps] == nil {
+				rates[gpu.NvlinkLineRateMBps] = make(map[uint32]struct{})
+			}
+			rates[gpu.NvlinkLineRateMBps][partition.ID] = struct{}{}
+		}
+	}
+	if len(rates) > 1 {
+		var values []uint32
+		for rate := range rates {
+			values = append(values, rate)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		for _, rate := range values {
+			ids := sortedIDs(rates[rate])
+			add(CheckLineRate, SeverityWarning, ids, "line rate %d MB/s used in partitions %s", rate, formatIDs(ids))
+		}
+	}
+
+	for _, partition := range partitions {
+		for _, gpu := range partition.GPUs {
+			if gpu.NumNvLinksAvailable > gpu.MaxNumNvLinks {
+				add(CheckNvlinkCount, SeverityWarning, []uint32{partition.ID},
+					"GPU %d in partition %d reports %d NVLinks available out of %d",
+					gpu.PhysicalID, partition.ID, gpu.NumNvLinksAvailable, gpu.MaxNumNvLinks)
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Severity == SeverityError && violations[j].Severity != SeverityError
+	})
+	return violations
+}
+
+// sortedIDs returns the members of an ID set in increasing order
+func sortedIDs(set map[uint32]struct{}) []uint32 {
+	ids := make([]uint32, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// VerifyFabric checks the supported partition table of the connected FabricManager
+func (c *Client) VerifyFabric() ([]Violation, error) {
+	partitions, err := c.GetSupportedPartitions()
+	if err != nil {
+		return nil, err
+	}
+	return VerifyFabric(partitions), nil
+}
@@ -0,0 +1,57 @@
+package fabricmanager
+
+import (
+	"testing"
+)
+
+// violationChecks counts the violations of each check
+func violationChecks(violations []Violation) map[string]int {
+	checks := make(map[string]int)
+	for _, violation := range violations {
+		checks[violation.Check]++
+	}
+	return checks
+}
+
+func TestVerifyFabricHealthy(t *testing.T) {
+	if violations := VerifyFabric(testPartitionTable(1, 5, 6)); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+}
+
+func TestVerifyFabricViolations(t *testing.T) {
+	partitions := testPartitionTable(1, 3)
+	// GPU 2 changed UUID in its 1-GPU partition
+	partitions[9].GPUs[0].UUID = "GPU-changed"
+	// Partition 6 reports a mixed line rate and an inconsistent GPU count
+	partitions[6].GPUs[1].NvlinkLineRateMBps = 20000
+	partitions[6].NumGPUs = 3
+	partitions = append(partitions, testPartition(FM_MAX_FABRIC_PARTITIONS, false, 0))
+
+	violations := VerifyFabric(partitions)
+	checks := violationChecks(violations)
+
+	if checks[CheckGPUIdentity] != 1 {
+		t.Errorf("Expected 1 GPU identity violation, got %d", checks[CheckGPUIdentity])
+	}
+	// Partitions 1 and 3 share GPUs 0 and 1
+	if checks[CheckActiveOverlap] != 2 {
+		t.Errorf("Expected 2 active overlap violations, got %d", checks[CheckActiveOverlap])
+	}
+	if checks[CheckLineRate] != 2 {
+		t.Errorf("Expected 2 line rate violations, got %d", checks[CheckLineRate])
+	}
+	if checks[CheckGPUCount] != 1 {
+		t.Errorf("Expected 1 GPU count violation, got %d", checks[CheckGPUCount])
+	}
+	if checks[CheckPartitionID] != 1 {
+		t.Errorf("Expected 1 partition ID violation, got %d", checks[CheckPartitionID])
+	}
+
+	if !HasErrors(violations) {
+		t.Error("Expected errors to be reported")
+	}
+	if violations[len(violations)-1].Severity != SeverityWarning {
+		t.Error("Expected warnings to be sorted after errors")
+	}
+}